@@ -1,17 +1,86 @@
 package main
 
 import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
 	"testing"
 )
 
 func BenchmarkProcessFiles(b *testing.B) {
 	for i := 0; i < b.N; i++ {
-		ProcessFiles("tmp", HelperUtils{loadDataToStruct, GetUniqueKey, hardCheck, getAllFiles})
+		ProcessFiles("tmp", HelperUtils{readData, parseCityFile, GetUniqueKey, hardCheck, getAllFiles})
 	}
 }
 
 func BenchmarkProcessFilesUsingChannels(b *testing.B) {
 	for i := 0; i < b.N; i++ {
-		ProcessFilesWithoutMutex("tmp", HelperUtils{loadDataToStruct, GetUniqueKey, hardCheck, getAllFiles})
+		ProcessFilesWithoutMutex("tmp", HelperUtils{readData, parseCityFile, GetUniqueKey, hardCheck, getAllFiles})
 	}
 }
+
+// benchmarkSizes synthetic datasets the engines are compared on
+var benchmarkSizes = []struct {
+	files, records int
+}{
+	{100, 10},
+	{1000, 10},
+	{100, 100},
+	{1000, 100},
+}
+
+// benchmarkWorkers worker counts of the pooled engines, 0 is GOMAXPROCS
+var benchmarkWorkers = []int{1, 4, 0}
+
+// BenchmarkEngines runs every engine over generated datasets, e.g.
+// go test -bench 'Engines/files=1000/records=100' -cpu 1,4,8
+func BenchmarkEngines(b *testing.B) {
+	if _, err := loadAuthenticCities("cities.json", loadDataToStruct, GetUniqueKey, FirstWins); err != nil {
+		b.Fatal(err)
+	}
+	defer slog.SetDefault(slog.Default())
+	slog.SetDefault(slog.New(slog.NewTextHandler(io.Discard, nil)))
+
+	reference, err := loadDataToStruct("cities.json")
+	if err != nil {
+		b.Fatal(err)
+	}
+	helpers := HelperUtils{readData, parseCityFile, GetUniqueKey, hardCheck, getAllFiles}
+
+	for _, size := range benchmarkSizes {
+		dir := b.TempDir()
+		opts := DatasetOptions{Files: size.files, RecordsPerFile: size.records, InvalidRatio: 0.2, CorruptRatio: 0.01, Seed: 1}
+		if _, err := GenerateDataset(dir, reference, opts); err != nil {
+			b.Fatal(err)
+		}
+		records := size.files * size.records
+		name := fmt.Sprintf("files=%d/records=%d", size.files, size.records)
+
+		for _, spec := range engines {
+			workerCounts := benchmarkWorkers
+			if !spec.pooled {
+				workerCounts = []int{0}
+			}
+			for _, workers := range workerCounts {
+				engine := spec.new(workers, true)
+				benchName := name + "/engine=" + spec.name
+				if spec.pooled {
+					benchName += fmt.Sprintf("/workers=%d", workers)
+				}
+				b.Run(benchName, func(b *testing.B) {
+					for i := 0; i < b.N; i++ {
+						if _, err := engine.Process(context.Background(), dir, helpers); err != nil {
+							b.Fatal(err)
+						}
+					}
+					reportRecordRate(b, records)
+				})
+			}
+		}
+	}
+}
+
+func reportRecordRate(b *testing.B, records int) {
+	b.ReportMetric(float64(records)*float64(b.N)/b.Elapsed().Seconds(), "records/s")
+}
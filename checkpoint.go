@@ -0,0 +1,165 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+)
+
+// checkpointVersion bumped whenever the checkpoint format changes
+const checkpointVersion = 1
+
+// checkpointFile result of a completed file and the content it was computed from
+type checkpointFile struct {
+	Checksum string `json:"checksum"`
+	cacheEntry
+}
+
+// checkpointState on-disk format of a checkpoint. A checkpoint is only resumed
+// by a run over the same folder with the same reference data and normalization.
+type checkpointState struct {
+	Version           int                       `json:"version"`
+	Dir               string                    `json:"dir"`
+	ReferenceSnapshot string                    `json:"reference_snapshot"`
+	Normalization     string                    `json:"normalization"`
+	Files             map[string]checkpointFile `json:"files"`
+}
+
+// Checkpointer records the files a run has completed and writes them to Path
+// at most every Interval, so a killed run can be resumed. A nil Checkpointer
+// does nothing.
+type Checkpointer struct {
+	Path     string
+	Interval time.Duration
+
+	mu       sync.Mutex
+	state    checkpointState
+	resumed  map[string]checkpointFile
+	reused   int
+	stale    bool
+	lastSave time.Time
+	err      error
+}
+
+// NewCheckpointer checkpointer writing to path. With resume the files completed
+// by the run that wrote path are not validated again, as long as they are unchanged.
+func NewCheckpointer(path string, interval time.Duration, resume bool) (*Checkpointer, error) {
+	c := &Checkpointer{Path: path, Interval: interval}
+	if !resume {
+		return c, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return c, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, &c.state); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	c.resumed = c.state.Files
+	return c, nil
+}
+
+// begin starts a run over dir against the reference snapshot, dropping resumed
+// files that were checked against other reference data
+func (c *Checkpointer) begin(dir, snapshot string) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	state := checkpointState{
+		Version:           checkpointVersion,
+		Dir:               dir,
+		ReferenceSnapshot: snapshot,
+		Normalization:     fmt.Sprint(nameNormalization),
+		Files:             make(map[string]checkpointFile),
+	}
+	if c.resumed != nil && (c.state.Version != state.Version || c.state.Dir != state.Dir ||
+		c.state.ReferenceSnapshot != state.ReferenceSnapshot || c.state.Normalization != state.Normalization) {
+		c.resumed, c.stale = nil, true
+	}
+	c.state = state
+	c.lastSave = time.Now()
+}
+
+// lookup result of path from the resumed checkpoint when its content is unchanged
+func (c *Checkpointer) lookup(path, checksum string) (FileResult, bool) {
+	if c == nil {
+		return FileResult{}, false
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	file, ok := c.resumed[path]
+	if !ok || file.Checksum != checksum {
+		return FileResult{}, false
+	}
+	c.state.Files[path] = file
+	c.reused++
+	return file.result(path), true
+}
+
+// done records the result of path and writes the checkpoint when Interval has passed
+func (c *Checkpointer) done(path, checksum string, result FileResult) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.state.Files[path] = checkpointFile{Checksum: checksum, cacheEntry: newCacheEntry(result)}
+	if time.Since(c.lastSave) >= c.Interval && c.err == nil {
+		c.err = c.save()
+		c.lastSave = time.Now()
+	}
+}
+
+func (c *Checkpointer) save() error {
+	data, err := json.Marshal(c.state)
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(c.Path, data)
+}
+
+// Err first error writing the checkpoint
+func (c *Checkpointer) Err() error {
+	if c == nil {
+		return nil
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.err
+}
+
+// Resumed number of files taken from the resumed checkpoint
+func (c *Checkpointer) Resumed() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.reused
+}
+
+// Stale reports whether the resumed checkpoint was discarded because it was
+// written by a run over other files or reference data
+func (c *Checkpointer) Stale() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.stale
+}
+
+// Finish removes the checkpoint once the run completed
+func (c *Checkpointer) Finish() error {
+	err := os.Remove(c.Path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	return err
+}
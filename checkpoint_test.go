@@ -0,0 +1,115 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sync/atomic"
+	"testing"
+)
+
+// comparableResults file results with errors replaced by their message
+func comparableResults(results []FileResult) []string {
+	var out []string
+	for _, r := range results {
+		out = append(out, fmt.Sprintf("%s %v %+v", r.Path, r.Err, r.Records))
+	}
+	return out
+}
+
+func TestCheckpointResume(t *testing.T) {
+	useReference(map[Key]LocationData{GetUniqueKey(oslo): oslo}, "v1")
+
+	dir := t.TempDir()
+	writeReferenceFile(t, filepath.Join(dir, "city-1.json"), []LocationData{oslo, rabat})
+	if err := os.WriteFile(filepath.Join(dir, "city-2.json"), []byte("[{"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	writeReferenceFile(t, filepath.Join(dir, "city-3.json"), []LocationData{oslo})
+	checkpointPath := filepath.Join(t.TempDir(), "checkpoint.json")
+
+	var loads atomic.Int32
+	helpers := HelperUtils{func(path string) ([]byte, error) {
+		loads.Add(1)
+		return readData(path)
+	}, parseCityFile, GetUniqueKey, hardCheck, getAllFiles}
+
+	// a run that gets killed before it finishes leaves its checkpoint behind
+	killed, err := NewCheckpointer(checkpointPath, 0, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ProcessFileResultsWith(dir, helpers, RunOptions{Ordered: true, Checkpoint: killed}); err != nil {
+		t.Fatal(err)
+	}
+	writeReferenceFile(t, filepath.Join(dir, "city-3.json"), []LocationData{rabat})
+	writeReferenceFile(t, filepath.Join(dir, "city-4.json"), []LocationData{oslo})
+
+	tests := []struct {
+		name        string
+		snapshot    string
+		wantResumed int
+		wantLoads   int32
+		wantStale   bool
+	}{
+		{"resume skips unchanged files", "v1", 2, 2, false},
+		{"other reference data starts over", "v2", 0, 4, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			useReference(map[Key]LocationData{GetUniqueKey(oslo): oslo}, tt.snapshot)
+			loads.Store(0)
+
+			checkpoint, err := NewCheckpointer(checkpointPath, 0, true)
+			if err != nil {
+				t.Fatal(err)
+			}
+			results, err := ProcessFileResultsWith(dir, helpers, RunOptions{Ordered: true, Checkpoint: checkpoint})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if got := checkpoint.Resumed(); got != tt.wantResumed {
+				t.Errorf("Resumed() = %d, want %d", got, tt.wantResumed)
+			}
+			if got := loads.Load(); got != tt.wantLoads {
+				t.Errorf("loaded %d files, want %d", got, tt.wantLoads)
+			}
+			if got := checkpoint.Stale(); got != tt.wantStale {
+				t.Errorf("Stale() = %v, want %v", got, tt.wantStale)
+			}
+
+			uninterrupted, err := ProcessFileResults(dir, helpers)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got, want := comparableResults(results), comparableResults(uninterrupted); !reflect.DeepEqual(got, want) {
+				t.Errorf("resumed results = %v, want %v", got, want)
+			}
+		})
+	}
+}
+
+func TestCheckpointFinish(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "checkpoint.json")
+	checkpoint, err := NewCheckpointer(path, 0, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkpoint.begin("tmp", "v1")
+	checkpoint.done("tmp/city-1.json", "sum", FileResult{Path: "tmp/city-1.json"})
+	if _, err := os.Stat(path); err != nil {
+		t.Fatalf("checkpoint not written: %v", err)
+	}
+
+	if err := checkpoint.Finish(); err != nil {
+		t.Fatalf("Finish() error = %v", err)
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Errorf("checkpoint still present after Finish()")
+	}
+	if err := checkpoint.Finish(); err != nil {
+		t.Errorf("second Finish() error = %v", err)
+	}
+}
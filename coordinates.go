@@ -0,0 +1,453 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// hemisphereTolerance widens every bounding box by this many degrees so that
+// cities sitting right on a border still land inside their country.
+const hemisphereTolerance = 0.75
+
+var (
+	// ErrHemisphereUnresolved is returned when no sign combination places the
+	// coordinates inside the country of the record.
+	ErrHemisphereUnresolved = errors.New("hemisphere could not be resolved")
+	// ErrHemisphereAmbiguous is returned when more than one sign combination
+	// fits the country, e.g. cities close to the equator or the prime meridian.
+	ErrHemisphereAmbiguous = errors.New("hemisphere is ambiguous")
+)
+
+// GeoPoint signed position in decimal degrees, north and east are positive
+type GeoPoint struct {
+	Lat float64 `json:"lat"`
+	Lon float64 `json:"lon"`
+}
+
+// boundingBox signed area in degrees, MinLon > MaxLon means the box crosses the antimeridian
+type boundingBox struct {
+	MinLat, MaxLat float64
+	MinLon, MaxLon float64
+}
+
+func (b boundingBox) contains(p GeoPoint) bool {
+	if p.Lat < b.MinLat-hemisphereTolerance || p.Lat > b.MaxLat+hemisphereTolerance {
+		return false
+	}
+	if b.MinLon <= b.MaxLon {
+		return p.Lon >= b.MinLon-hemisphereTolerance && p.Lon <= b.MaxLon+hemisphereTolerance
+	}
+	return p.Lon >= b.MinLon-hemisphereTolerance || p.Lon <= b.MaxLon+hemisphereTolerance
+}
+
+// parseCoordinate reads a single latitude or longitude value written in the
+// degrees.minutes notation of cities.json ("25.40" is 25°40') and returns it in
+// decimal degrees. The sign is taken from a leading '-'/'+' or from an N/S/E/W
+// marker before or after the number, explicit reports whether any of those was present.
+func parseCoordinate(value string, positive, negative byte) (deg float64, explicit bool, err error) {
+	v := strings.ToUpper(strings.TrimSpace(value))
+	sign := 1.0
+
+	if v != "" {
+		switch {
+		case v[0] == positive, v[len(v)-1] == positive:
+			explicit = true
+		case v[0] == negative, v[len(v)-1] == negative:
+			explicit = true
+			sign = -1
+		}
+		if explicit {
+			v = strings.TrimSpace(strings.Trim(v, string([]byte{positive, negative})))
+		}
+	}
+
+	deg, err = strconv.ParseFloat(v, 64)
+	if err != nil {
+		return 0, false, fmt.Errorf("invalid coordinate %q: %w", value, err)
+	}
+	if math.IsNaN(deg) || math.IsInf(deg, 0) {
+		return 0, false, fmt.Errorf("invalid coordinate %q: not a finite number", value)
+	}
+	if strings.HasPrefix(v, "-") || strings.HasPrefix(v, "+") {
+		if explicit {
+			return 0, false, fmt.Errorf("invalid coordinate %q: sign given twice", value)
+		}
+		explicit = true
+	}
+
+	return sign * fromDegreesMinutes(deg), explicit, nil
+}
+
+// fromDegreesMinutes converts degrees.minutes to decimal degrees, the two
+// digits after the point are minutes: 25.40 is 25 + 40/60 = 25.667
+func fromDegreesMinutes(v float64) float64 {
+	deg, minutes := math.Modf(math.Abs(v))
+	return math.Copysign(deg+minutes*100/60, v)
+}
+
+// hemisphereSigns reads a hemisphere field such as "N", "SW" or "S,E" and
+// returns the signs it fixes, 0 means the field says nothing about that axis.
+func hemisphereSigns(hemisphere string) (latSign, lonSign float64, err error) {
+	for _, r := range strings.ToUpper(hemisphere) {
+		switch r {
+		case 'N':
+			latSign = 1
+		case 'S':
+			latSign = -1
+		case 'E':
+			lonSign = 1
+		case 'W':
+			lonSign = -1
+		case ' ', ',', '/', '-':
+		default:
+			return 0, 0, fmt.Errorf("invalid hemisphere %q", hemisphere)
+		}
+	}
+	return latSign, lonSign, nil
+}
+
+// ResolveGeoPoint turns the unsigned latitude/longitude of a record into a
+// signed position. Signs come, in order of precedence, from the coordinate
+// strings themselves, from the Hemisphere field and finally from the
+// countryBounds table. On ErrHemisphereAmbiguous the northern/eastern
+// candidate is still returned so callers can decide whether to accept it.
+func ResolveGeoPoint(city LocationData) (GeoPoint, error) {
+	lat, latExplicit, err := parseCoordinate(city.Latitude, 'N', 'S')
+	if err != nil {
+		return GeoPoint{}, err
+	}
+	lon, lonExplicit, err := parseCoordinate(city.Longitude, 'E', 'W')
+	if err != nil {
+		return GeoPoint{}, err
+	}
+	if lat < -90 || lat > 90 || lon < -180 || lon > 180 {
+		return GeoPoint{}, fmt.Errorf("coordinates %s, %s out of range", city.Latitude, city.Longitude)
+	}
+
+	latSign, lonSign, err := hemisphereSigns(city.Hemisphere)
+	if err != nil {
+		return GeoPoint{}, err
+	}
+	if !latExplicit && latSign != 0 {
+		lat, latExplicit = latSign*lat, true
+	}
+	if !lonExplicit && lonSign != 0 {
+		lon, lonExplicit = lonSign*lon, true
+	}
+
+	candidates := []GeoPoint{{Lat: lat, Lon: lon}}
+	if !latExplicit && lat != 0 {
+		candidates = append(candidates, GeoPoint{Lat: -lat, Lon: lon})
+	}
+	if !lonExplicit && lon != 0 && lon != 180 {
+		for _, c := range candidates {
+			candidates = append(candidates, GeoPoint{Lat: c.Lat, Lon: -c.Lon})
+		}
+	}
+	if len(candidates) == 1 {
+		return candidates[0], nil
+	}
+
+	boxes, ok := countryBounds[city.Country]
+	if !ok {
+		return GeoPoint{}, fmt.Errorf("%w: no bounds for country %q", ErrHemisphereUnresolved, city.Country)
+	}
+
+	var matches []GeoPoint
+	for _, c := range candidates {
+		for _, box := range boxes {
+			if box.contains(c) {
+				matches = append(matches, c)
+				break
+			}
+		}
+	}
+
+	switch len(matches) {
+	case 0:
+		return GeoPoint{}, fmt.Errorf("%w: %s, %s is outside %s", ErrHemisphereUnresolved, city.Latitude, city.Longitude, city.Country)
+	case 1:
+		return matches[0], nil
+	default:
+		return matches[0], fmt.Errorf("%w: %d candidates inside %s", ErrHemisphereAmbiguous, len(matches), city.Country)
+	}
+}
+
+// countryBounds approximate signed extents of every country in cities.json,
+// overseas territories and Antarctic stations listed under a country get a
+// box of their own.
+var countryBounds = map[string][]boundingBox{
+	"Afghanistan":         {{29.3, 38.5, 60.5, 75.0}},
+	"Albania":             {{39.6, 42.7, 19.2, 21.1}},
+	"Algeria":             {{18.9, 37.1, -8.7, 12.0}},
+	"Andorra":             {{42.4, 42.7, 1.4, 1.8}},
+	"Angola":              {{-18.1, -4.3, 11.6, 24.1}},
+	"Antigua and Barbuda": {{16.9, 17.8, -62.4, -61.6}},
+	"Argentina": {
+		{-55.1, -21.7, -73.6, -53.6},
+		{-90.0, -60.0, -75.0, -25.0}, // Antarctic bases
+	},
+	"Armenia": {{38.8, 41.3, 43.4, 46.7}},
+	"Australia": {
+		{-43.7, -10.0, 112.9, 153.7},
+		{-29.2, -28.9, 167.8, 168.1}, // Norfolk Island
+	},
+	"Austria":                  {{46.3, 49.1, 9.5, 17.2}},
+	"Azerbaijan":               {{38.3, 41.9, 44.7, 50.4}},
+	"Bahamas":                  {{20.9, 27.3, -79.6, -72.7}},
+	"Bahrain":                  {{25.7, 26.3, 50.3, 50.7}},
+	"Bangladesh":               {{20.6, 26.7, 88.0, 92.7}},
+	"Barbados":                 {{13.0, 13.4, -59.7, -59.4}},
+	"Belarus":                  {{51.2, 56.2, 23.1, 32.8}},
+	"Belgium":                  {{49.5, 51.5, 2.5, 6.4}},
+	"Belize":                   {{15.8, 18.5, -89.3, -87.4}},
+	"Benin":                    {{6.2, 12.4, 0.7, 3.9}},
+	"Bhutan":                   {{26.7, 28.4, 88.7, 92.2}},
+	"Bolivia":                  {{-22.9, -9.6, -69.7, -57.4}},
+	"Bosnia and Herzegovina":   {{42.5, 45.3, 15.7, 19.7}},
+	"Botswana":                 {{-26.9, -17.7, 19.9, 29.4}},
+	"Brazil":                   {{-33.8, 5.3, -74.0, -28.8}},
+	"Brunei":                   {{4.0, 5.1, 114.0, 115.4}},
+	"Bulgaria":                 {{41.2, 44.3, 22.3, 28.7}},
+	"Burkina Faso":             {{9.4, 15.1, -5.6, 2.4}},
+	"Burundi":                  {{-4.5, -2.3, 29.0, 30.9}},
+	"Cambodia":                 {{10.4, 14.7, 102.3, 107.7}},
+	"Cameroon":                 {{1.6, 13.1, 8.4, 16.2}},
+	"Canada":                   {{41.6, 83.2, -141.1, -52.6}},
+	"Cape Verde":               {{14.8, 17.2, -25.4, -22.6}},
+	"Central African Republic": {{2.2, 11.1, 14.4, 27.5}},
+	"Chad":                     {{7.4, 23.5, 13.4, 24.0}},
+	"Chile": {
+		{-56.0, -17.5, -75.7, -66.4},
+		{-27.3, -27.0, -109.5, -109.2}, // Easter Island
+		{-90.0, -60.0, -90.0, -53.0},   // Antarctic bases
+	},
+	"Colombia":                         {{-4.3, 13.4, -81.8, -66.8}},
+	"Comoros":                          {{-12.5, -11.3, 43.2, 44.6}},
+	"Costa Rica":                       {{5.5, 11.3, -87.1, -82.5}},
+	"Croatia":                          {{42.4, 46.6, 13.4, 19.5}},
+	"Cuba":                             {{19.8, 23.3, -85.0, -74.1}},
+	"Cyprus":                           {{34.5, 35.7, 32.2, 34.6}},
+	"Czech Republic":                   {{48.5, 51.1, 12.0, 18.9}},
+	"Democratic Republic of the Congo": {{-13.5, 5.4, 12.2, 31.4}},
+	"Denmark": {
+		{54.5, 57.8, 8.0, 15.2},
+		{61.3, 62.4, -7.7, -6.2},   // Faroe Islands
+		{59.7, 83.7, -73.3, -11.3}, // Greenland
+	},
+	"Djibouti":           {{10.9, 12.7, 41.7, 43.4}},
+	"Dominica":           {{15.2, 15.7, -61.5, -61.2}},
+	"Dominican Republic": {{17.5, 19.9, -72.0, -68.3}},
+	"Ecuador": {
+		{-5.0, 1.5, -81.1, -75.2},
+		{-1.5, 0.7, -92.0, -89.2}, // Galápagos
+	},
+	"Egypt":                          {{22.0, 31.7, 24.7, 36.9}},
+	"El Salvador":                    {{13.1, 14.5, -90.2, -87.7}},
+	"Equatorial Guinea":              {{-1.5, 3.8, 5.6, 11.4}},
+	"Eritrea":                        {{12.4, 18.0, 36.4, 43.2}},
+	"Estonia":                        {{57.5, 59.7, 21.8, 28.2}},
+	"Ethiopia":                       {{3.4, 14.9, 33.0, 48.0}},
+	"Federated States of Micronesia": {{5.2, 10.1, 137.3, 163.1}},
+	"Fiji":                           {{-21.0, -12.4, 176.8, -178.2}},
+	"Finland":                        {{59.7, 70.1, 20.5, 31.6}},
+	"France": {
+		{41.3, 51.1, -5.2, 9.6},
+		{46.7, 47.2, -56.5, -56.1},     // Saint Pierre and Miquelon
+		{15.8, 16.6, -61.9, -61.0},     // Guadeloupe
+		{14.3, 14.9, -61.3, -60.8},     // Martinique
+		{2.1, 5.8, -54.6, -51.6},       // French Guiana
+		{-13.1, -12.6, 45.0, 45.3},     // Mayotte
+		{-14.4, -13.2, -178.2, -176.1}, // Wallis and Futuna
+		{-27.7, -7.8, -154.8, -134.4},  // French Polynesia
+		{-21.4, -20.8, 55.2, 55.9},     // Réunion
+		{-22.9, -19.5, 163.5, 168.2},   // New Caledonia
+	},
+	"Gabon":         {{-4.0, 2.3, 8.7, 14.5}},
+	"Gambia":        {{13.0, 13.9, -16.9, -13.8}},
+	"Georgia":       {{41.0, 43.6, 40.0, 46.7}},
+	"Germany":       {{47.3, 55.1, 5.9, 15.0}},
+	"Ghana":         {{4.7, 11.2, -3.3, 1.2}},
+	"Greece":        {{34.8, 41.8, 19.4, 29.7}},
+	"Grenada":       {{11.9, 12.6, -61.8, -61.4}},
+	"Guatemala":     {{13.7, 17.8, -92.3, -88.2}},
+	"Guinea":        {{7.2, 12.7, -15.1, -7.6}},
+	"Guinea-Bissau": {{10.9, 12.7, -16.8, -13.6}},
+	"Guyana":        {{1.2, 8.6, -61.4, -56.5}},
+	"Haiti":         {{18.0, 20.1, -74.5, -71.6}},
+	"Honduras":      {{12.9, 17.5, -89.4, -83.1}},
+	"Hungary":       {{45.7, 48.6, 16.1, 22.9}},
+	"Iceland":       {{63.3, 66.6, -24.6, -13.5}},
+	"India":         {{6.7, 35.7, 68.1, 97.4}},
+	"Indonesia":     {{-11.0, 6.1, 95.0, 141.1}},
+	"Iran":          {{25.0, 39.8, 44.0, 63.4}},
+	"Iraq":          {{29.0, 37.4, 38.8, 48.6}},
+	"Ireland":       {{51.4, 55.4, -10.5, -6.0}},
+	"Israel":        {{29.5, 33.3, 34.3, 35.9}},
+	"Italy":         {{35.5, 47.1, 6.6, 18.5}},
+	"Ivory Coast":   {{4.3, 10.7, -8.6, -2.5}},
+	"Jamaica":       {{17.7, 18.5, -78.4, -76.2}},
+	"Japan":         {{24.0, 45.6, 122.9, 146.0}},
+	"Jordan":        {{29.2, 33.4, 34.9, 39.3}},
+	"Kazakhstan":    {{40.6, 55.4, 46.5, 87.3}},
+	"Kenya":         {{-4.7, 5.0, 33.9, 41.9}},
+	"Kiribati": {
+		{-2.7, 3.4, 172.6, 177.0},    // Gilbert Islands
+		{-4.8, -2.7, -175.0, -170.0}, // Phoenix Islands
+		{-11.5, 4.8, -162.4, -150.2}, // Line Islands
+	},
+	"Kuwait":           {{28.5, 30.1, 46.5, 48.5}},
+	"Kyrgyzstan":       {{39.2, 43.3, 69.3, 80.3}},
+	"Laos":             {{13.9, 22.5, 100.1, 107.7}},
+	"Latvia":           {{55.7, 58.1, 20.9, 28.3}},
+	"Lebanon":          {{33.0, 34.7, 35.1, 36.7}},
+	"Lesotho":          {{-30.7, -28.6, 27.0, 29.5}},
+	"Liberia":          {{4.3, 8.6, -11.5, -7.4}},
+	"Libya":            {{19.5, 33.2, 9.3, 25.2}},
+	"Liechtenstein":    {{47.0, 47.3, 9.5, 9.7}},
+	"Lithuania":        {{53.9, 56.5, 21.0, 26.9}},
+	"Luxembourg":       {{49.4, 50.2, 5.7, 6.6}},
+	"Macedonia":        {{40.8, 42.4, 20.4, 23.1}},
+	"Madagascar":       {{-25.7, -11.9, 43.2, 50.5}},
+	"Malawi":           {{-17.2, -9.3, 32.6, 36.0}},
+	"Malaysia":         {{0.8, 7.4, 99.6, 119.3}},
+	"Maldives":         {{-0.7, 7.2, 72.6, 73.8}},
+	"Mali":             {{10.1, 25.0, -12.3, 4.3}},
+	"Malta":            {{35.8, 36.1, 14.1, 14.6}},
+	"Marshall Islands": {{4.5, 14.7, 160.7, 172.2}},
+	"Mauritania":       {{14.7, 27.3, -17.1, -4.8}},
+	"Mauritius":        {{-20.6, -19.9, 57.3, 57.9}},
+	"Mexico":           {{14.5, 32.8, -118.5, -86.7}},
+	"Moldova":          {{45.4, 48.5, 26.6, 30.2}},
+	"Monaco":           {{43.7, 43.8, 7.4, 7.5}},
+	"Mongolia":         {{41.5, 52.2, 87.7, 119.9}},
+	"Montenegro":       {{41.8, 43.6, 18.4, 20.4}},
+	"Morocco":          {{21.0, 35.9, -17.1, -1.0}},
+	"Mozambique":       {{-26.9, -10.4, 30.2, 40.9}},
+	"Myanmar":          {{9.7, 28.6, 92.2, 101.2}},
+	"Namibia":          {{-29.0, -16.9, 11.7, 25.3}},
+	"Nauru":            {{-0.6, -0.5, 166.9, 167.0}},
+	"Nepal":            {{26.3, 30.5, 80.0, 88.2}},
+	"Netherlands": {
+		{50.7, 53.6, 3.3, 7.3},
+		{12.4, 12.7, -70.1, -69.8}, // Aruba
+		{12.0, 12.4, -69.2, -68.7}, // Curaçao
+	},
+	"New Zealand": {
+		{-47.4, -34.3, 166.4, 178.6},
+		{-44.4, -43.7, -177.0, -176.1}, // Chatham Islands
+		{-19.2, -18.9, -170.0, -169.7}, // Niue
+		{-22.0, -8.9, -166.0, -157.3},  // Cook Islands
+	},
+	"Nicaragua":   {{10.7, 15.1, -87.7, -82.6}},
+	"Niger":       {{11.7, 23.5, 0.2, 16.0}},
+	"Nigeria":     {{4.3, 13.9, 2.7, 14.7}},
+	"North Korea": {{37.7, 43.0, 124.2, 130.7}},
+	"Norway": {
+		{57.9, 71.2, 4.6, 31.1},
+		{76.4, 80.9, 10.5, 33.6}, // Svalbard
+	},
+	"Oman":                       {{16.6, 26.4, 52.0, 59.9}},
+	"Pakistan":                   {{23.6, 37.1, 60.9, 77.8}},
+	"Palau":                      {{2.8, 8.2, 131.1, 134.8}},
+	"Palestine":                  {{31.2, 32.6, 34.2, 35.6}},
+	"Panama":                     {{7.2, 9.7, -83.1, -77.2}},
+	"Papua New Guinea":           {{-11.7, -1.3, 140.8, 156.0}},
+	"Paraguay":                   {{-27.6, -19.3, -62.7, -54.3}},
+	"People's Republic of China": {{18.1, 53.6, 73.5, 134.8}},
+	"Peru":                       {{-18.4, 0.0, -81.4, -68.7}},
+	"Philippines":                {{4.6, 21.1, 116.9, 126.6}},
+	"Poland":                     {{49.0, 54.9, 14.1, 24.2}},
+	"Portugal": {
+		{36.9, 42.2, -9.6, -6.2},
+		{36.9, 39.8, -31.3, -25.0}, // Azores
+		{32.4, 33.1, -17.3, -16.3}, // Madeira
+	},
+	"Qatar":                            {{24.5, 26.2, 50.7, 51.7}},
+	"Republic of China (Taiwan)":       {{21.9, 25.3, 118.2, 122.1}},
+	"Republic of the Congo":            {{-5.1, 3.7, 11.1, 18.7}},
+	"Romania":                          {{43.6, 48.3, 20.2, 29.7}},
+	"Russia":                           {{41.2, 81.9, 19.6, -169.0}},
+	"Rwanda":                           {{-2.9, -1.0, 28.8, 30.9}},
+	"Saint Kitts and Nevis":            {{17.1, 17.5, -62.9, -62.5}},
+	"Saint Lucia":                      {{13.7, 14.1, -61.1, -60.9}},
+	"Saint Vincent and the Grenadines": {{12.5, 13.4, -61.5, -61.1}},
+	"Samoa":                            {{-14.1, -13.4, -172.8, -171.4}},
+	"San Marino":                       {{43.9, 44.0, 12.4, 12.5}},
+	"Saudi Arabia":                     {{16.3, 32.2, 34.5, 55.7}},
+	"Senegal":                          {{12.3, 16.7, -17.6, -11.3}},
+	"Serbia":                           {{42.2, 46.2, 18.8, 23.0}},
+	"Seychelles":                       {{-10.3, -3.7, 46.2, 56.3}},
+	"Sierra Leone":                     {{6.9, 10.0, -13.3, -10.3}},
+	"Singapore":                        {{1.2, 1.5, 103.6, 104.1}},
+	"Slovakia":                         {{47.7, 49.6, 16.8, 22.6}},
+	"Slovenia":                         {{45.4, 46.9, 13.4, 16.6}},
+	"Solomon Islands":                  {{-12.3, -6.6, 155.5, 170.2}},
+	"Somalia":                          {{-1.7, 12.0, 41.0, 51.4}},
+	"South Africa":                     {{-34.9, -22.1, 16.4, 32.9}},
+	"South Korea":                      {{33.1, 38.6, 124.6, 131.9}},
+	"South Sudan":                      {{3.5, 12.2, 23.4, 36.0}},
+	"Spain": {
+		{36.0, 43.8, -9.3, 4.3},
+		{27.6, 29.4, -18.2, -13.4}, // Canary Islands
+	},
+	"Sri Lanka":             {{5.9, 9.9, 79.7, 81.9}},
+	"Sudan":                 {{8.7, 22.2, 21.8, 38.6}},
+	"Suriname":              {{1.8, 6.0, -58.1, -54.0}},
+	"Swaziland":             {{-27.3, -25.7, 30.8, 32.1}},
+	"Sweden":                {{55.3, 69.1, 11.0, 24.2}},
+	"Switzerland":           {{45.8, 47.8, 5.9, 10.5}},
+	"Syria":                 {{32.3, 37.3, 35.7, 42.4}},
+	"São Tomé and Príncipe": {{-0.1, 1.7, 6.4, 7.5}},
+	"Tajikistan":            {{36.7, 41.0, 67.3, 75.2}},
+	"Tanzania":              {{-11.8, -1.0, 29.3, 40.5}},
+	"Thailand":              {{5.6, 20.5, 97.3, 105.7}},
+	"Timor-Leste":           {{-9.5, -8.1, 124.0, 127.4}},
+	"Togo":                  {{6.1, 11.2, -0.2, 1.9}},
+	"Tonga":                 {{-22.4, -15.5, -176.3, -173.7}},
+	"Trinidad and Tobago":   {{10.0, 11.4, -61.9, -60.5}},
+	"Tunisia":               {{30.2, 37.6, 7.5, 11.6}},
+	"Turkey":                {{35.8, 42.2, 25.6, 44.9}},
+	"Turkmenistan":          {{35.1, 42.8, 52.4, 66.7}},
+	"Tuvalu":                {{-10.8, -5.6, 176.0, 179.9}},
+	"Uganda":                {{-1.5, 4.3, 29.5, 35.1}},
+	"Ukraine":               {{44.3, 52.4, 22.1, 40.3}},
+	"United Arab Emirates":  {{22.6, 26.1, 51.5, 56.4}},
+	"United Kingdom": {
+		{49.8, 61.0, -8.7, 1.8},
+		{36.1, 36.2, -5.4, -5.3},       // Gibraltar
+		{32.2, 32.4, -64.9, -64.6},     // Bermuda
+		{21.2, 21.9, -72.5, -71.1},     // Turks and Caicos Islands
+		{19.2, 19.8, -81.5, -79.7},     // Cayman Islands
+		{18.3, 18.8, -64.9, -64.2},     // British Virgin Islands
+		{18.1, 18.3, -63.2, -62.9},     // Anguilla
+		{-16.1, -15.8, -5.8, -5.6},     // Saint Helena
+		{-25.1, -23.9, -130.8, -124.7}, // Pitcairn Islands
+		{-52.5, -51.0, -61.4, -57.7},   // Falkland Islands
+		{-55.0, -53.9, -38.1, -35.7},   // South Georgia
+		{-90.0, -60.0, -80.0, -20.0},   // British Antarctic Territory
+	},
+	"United States": {
+		{24.4, 49.4, -124.8, -66.9},
+		{51.2, 71.4, -180.0, -129.9},   // Alaska
+		{52.3, 53.0, 172.4, 174.2},     // Near Islands
+		{18.9, 22.3, -160.3, -154.8},   // Hawaii
+		{17.9, 18.6, -67.3, -65.2},     // Puerto Rico
+		{17.6, 18.5, -65.1, -64.5},     // U.S. Virgin Islands
+		{13.2, 20.6, 144.6, 146.1},     // Guam and Northern Mariana Islands
+		{-14.6, -11.0, -171.1, -168.1}, // American Samoa
+		{-90.0, -60.0, -180.0, 180.0},  // Antarctic stations
+	},
+	"Uruguay":      {{-35.0, -30.1, -58.5, -53.1}},
+	"Uzbekistan":   {{37.2, 45.6, 56.0, 73.1}},
+	"Vanuatu":      {{-20.3, -13.1, 166.5, 170.2}},
+	"Vatican City": {{41.9, 41.91, 12.44, 12.46}},
+	"Venezuela":    {{0.6, 12.2, -73.4, -59.8}},
+	"Vietnam":      {{8.4, 23.4, 102.1, 109.5}},
+	"Yemen":        {{12.1, 19.0, 42.5, 54.6}},
+	"Zambia":       {{-18.1, -8.2, 21.9, 33.7}},
+	"Zimbabwe":     {{-22.4, -15.6, 25.2, 33.1}},
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"errors"
+	"math"
+	"testing"
+)
+
+func TestResolveGeoPoint(t *testing.T) {
+	tests := []struct {
+		name    string
+		city    LocationData
+		want    GeoPoint
+		wantErr error
+	}{
+		{
+			name: "western hemisphere from country bounds",
+			city: LocationData{Latitude: "25.40", Longitude: "100.18", Country: "Mexico"},
+			want: GeoPoint{Lat: 25.667, Lon: -100.3},
+		},
+		{
+			name: "arctic city in canada",
+			city: LocationData{Latitude: "82.30", Longitude: "62.20", Country: "Canada"},
+			want: GeoPoint{Lat: 82.5, Lon: -62.333},
+		},
+		{
+			name: "southern and eastern hemisphere",
+			city: LocationData{Latitude: "33.52", Longitude: "151.13", Country: "Australia"},
+			want: GeoPoint{Lat: -33.867, Lon: 151.217},
+		},
+		{
+			name: "box crossing the antimeridian",
+			city: LocationData{Latitude: "17.45", Longitude: "177.26", Country: "Fiji"},
+			want: GeoPoint{Lat: -17.75, Lon: 177.433},
+		},
+		{
+			name: "overseas territory",
+			city: LocationData{Latitude: "51.42", Longitude: "57.52", Country: "United Kingdom"},
+			want: GeoPoint{Lat: -51.7, Lon: -57.867},
+		},
+		{
+			name: "explicit signs win over country bounds",
+			city: LocationData{Latitude: "-25.40", Longitude: "+100.18", Country: "Mexico"},
+			want: GeoPoint{Lat: -25.667, Lon: 100.3},
+		},
+		{
+			name: "hemisphere markers on the values",
+			city: LocationData{Latitude: "51.30N", Longitude: "W 0.08", Country: "United Kingdom"},
+			want: GeoPoint{Lat: 51.5, Lon: -0.133},
+		},
+		{
+			name: "hemisphere field",
+			city: LocationData{Latitude: "51.30", Longitude: "0.08", Country: "United Kingdom", Hemisphere: "NW"},
+			want: GeoPoint{Lat: 51.5, Lon: -0.133},
+		},
+		{
+			name:    "ambiguous near the prime meridian",
+			city:    LocationData{Latitude: "51.30", Longitude: "0.08", Country: "United Kingdom"},
+			want:    GeoPoint{Lat: 51.5, Lon: 0.133},
+			wantErr: ErrHemisphereAmbiguous,
+		},
+		{
+			name:    "coordinates outside the country",
+			city:    LocationData{Latitude: "11.38", Longitude: "79.42", Country: "Brazil"},
+			wantErr: ErrHemisphereUnresolved,
+		},
+		{
+			name:    "unknown country",
+			city:    LocationData{Latitude: "10.00", Longitude: "10.00", Country: "Atlantis"},
+			wantErr: ErrHemisphereUnresolved,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ResolveGeoPoint(tt.city)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("ResolveGeoPoint() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if math.Abs(got.Lat-tt.want.Lat) > 0.001 || math.Abs(got.Lon-tt.want.Lon) > 0.001 {
+				t.Errorf("ResolveGeoPoint() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestResolveGeoPoint_Invalid(t *testing.T) {
+	tests := []struct {
+		name string
+		city LocationData
+	}{
+		{"not a number", LocationData{Latitude: "north", Longitude: "10.00", Country: "Mexico"}},
+		{"sign given twice", LocationData{Latitude: "-25.40S", Longitude: "100.18", Country: "Mexico"}},
+		{"latitude out of range", LocationData{Latitude: "95.00", Longitude: "100.18", Country: "Mexico"}},
+		{"bad hemisphere field", LocationData{Latitude: "25.40", Longitude: "100.18", Country: "Mexico", Hemisphere: "up"}},
+		{"not finite", LocationData{Latitude: "25.40", Longitude: "NaN", Country: "Mexico"}},
+		{"infinite", LocationData{Latitude: "inf", Longitude: "100.18", Country: "Mexico"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := ResolveGeoPoint(tt.city); err == nil {
+				t.Errorf("ResolveGeoPoint() expected an error for %+v", tt.city)
+			}
+		})
+	}
+}
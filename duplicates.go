@@ -0,0 +1,69 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// DuplicatePolicy decides which entry survives when the reference data holds
+// more than one entry for the same Key
+type DuplicatePolicy string
+
+const (
+	FirstWins       DuplicatePolicy = "first"
+	LastWins        DuplicatePolicy = "last"
+	FailOnDuplicate DuplicatePolicy = "fail"
+	MergeDuplicates DuplicatePolicy = "merge" // keep the first, fill its empty fields from later entries
+)
+
+// ErrDuplicateReference returned by loadAuthenticCities under FailOnDuplicate
+var ErrDuplicateReference = errors.New("duplicate reference entry")
+
+// DuplicateConflict one extra entry found for an already loaded Key
+type DuplicateConflict struct {
+	Key       Key             `json:"key"`
+	Source    string          `json:"source"`    // reference file holding both entries
+	Index     int             `json:"index"`     // position of the later entry in the source
+	Existing  LocationData    `json:"existing"`  // entry loaded before the conflict was seen
+	Duplicate LocationData    `json:"duplicate"` // the later entry
+	Kept      LocationData    `json:"kept"`      // what the registry holds after resolving
+	Policy    DuplicatePolicy `json:"policy"`
+}
+
+// ParseDuplicatePolicy accepts first, last, fail or merge, "" means first
+func ParseDuplicatePolicy(s string) (DuplicatePolicy, error) {
+	switch policy := DuplicatePolicy(strings.ToLower(strings.TrimSpace(s))); policy {
+	case FirstWins, LastWins, FailOnDuplicate, MergeDuplicates:
+		return policy, nil
+	case "":
+		return FirstWins, nil
+	default:
+		return "", fmt.Errorf("unknown duplicate policy %q", s)
+	}
+}
+
+// resolve returns the entry to keep for existing and its later duplicate
+func (p DuplicatePolicy) resolve(existing, duplicate LocationData) LocationData {
+	switch p {
+	case LastWins:
+		return duplicate
+	case MergeDuplicates:
+		return mergeEmptyFields(existing, duplicate)
+	default:
+		return existing
+	}
+}
+
+// mergeEmptyFields fills the empty string fields of dst from src
+func mergeEmptyFields(dst, src LocationData) LocationData {
+	d := reflect.ValueOf(&dst).Elem()
+	s := reflect.ValueOf(src)
+	for i := 0; i < d.NumField(); i++ {
+		if f := d.Field(i); f.Kind() == reflect.String && f.String() == "" {
+			f.SetString(s.Field(i).String())
+		}
+	}
+	return dst
+}
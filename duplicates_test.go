@@ -0,0 +1,112 @@
+package main
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestLoadAuthenticCities_DuplicatePolicy(t *testing.T) {
+	first := LocationData{Name: "Oslo", Country: "Norway", Geo: "59.57, 10.45", Province: "Oslo"}
+	second := LocationData{Name: "Oslo", Country: "Norway", Geo: "59.57, 10.45", CountryIcon: "no.png"}
+	other := LocationData{Name: "Bergen", Country: "Norway", Geo: "60.23, 5.20"}
+	loader := func(string) ([]LocationData, error) {
+		return []LocationData{first, other, second}, nil
+	}
+
+	tests := []struct {
+		policy  DuplicatePolicy
+		want    LocationData
+		wantErr bool
+	}{
+		{FirstWins, first, false},
+		{LastWins, second, false},
+		{MergeDuplicates, LocationData{Name: "Oslo", Country: "Norway", Geo: "59.57, 10.45", Province: "Oslo", CountryIcon: "no.png"}, false},
+		{FailOnDuplicate, LocationData{}, true},
+	}
+	for _, tt := range tests {
+		t.Run(string(tt.policy), func(t *testing.T) {
+			useReference(map[Key]LocationData{}, "")
+
+			conflicts, err := loadAuthenticCities("cities", loader, GetUniqueKey, tt.policy)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("loadAuthenticCities() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if len(conflicts) != 1 {
+				t.Fatalf("got %d conflicts, want 1", len(conflicts))
+			}
+			c := conflicts[0]
+			if c.Index != 2 || c.Existing != first || c.Duplicate != second || c.Policy != tt.policy {
+				t.Errorf("unexpected conflict %+v", c)
+			}
+
+			if tt.wantErr {
+				if !errors.Is(err, ErrDuplicateReference) {
+					t.Errorf("error = %v, want ErrDuplicateReference", err)
+				}
+				if len(loadedReference().cities) != 0 {
+					t.Errorf("registry was replaced despite the failure")
+				}
+				return
+			}
+			if c.Kept != tt.want {
+				t.Errorf("Kept = %+v, want %+v", c.Kept, tt.want)
+			}
+			if got := loadedReference().cities[GetUniqueKey(first)]; got != tt.want {
+				t.Errorf("registry holds %+v, want %+v", got, tt.want)
+			}
+			if len(loadedReference().cities) != 2 {
+				t.Errorf("registry size = %d, want 2", len(loadedReference().cities))
+			}
+		})
+	}
+}
+
+func TestLoadAuthenticCities_SnapshotOfLoadedEntries(t *testing.T) {
+	first := LocationData{Name: "Oslo", Country: "Norway", Geo: "59.57, 10.45", Province: "Oslo"}
+	second := LocationData{Name: "Oslo", Country: "Norway", Geo: "59.57, 10.45", CountryIcon: "no.png"}
+	other := LocationData{Name: "Bergen", Country: "Norway", Geo: "60.23, 5.20"}
+	files := map[string][]LocationData{
+		"duplicates": {first, other, second},
+		"plain":      {first, other},
+		"copy":       {first, other},
+	}
+	loader := func(path string) ([]LocationData, error) { return files[path], nil }
+	snapshot := func(paths []string, policy DuplicatePolicy) string {
+		t.Helper()
+		if _, err := loadReferenceLayers(paths, loader, GetUniqueKey, policy); err != nil {
+			t.Fatal(err)
+		}
+		return loadedReference().snapshot
+	}
+
+	// the same files under another policy load other entries
+	seen := make(map[string]DuplicatePolicy)
+	for _, policy := range []DuplicatePolicy{FirstWins, LastWins, MergeDuplicates} {
+		got := snapshot([]string{"duplicates"}, policy)
+		if prev, ok := seen[got]; ok {
+			t.Errorf("policies %s and %s give the same snapshot %s", prev, policy, got)
+		}
+		seen[got] = policy
+	}
+	if got := snapshot([]string{"duplicates"}, FirstWins); got != snapshot([]string{"plain"}, FirstWins) {
+		t.Errorf("snapshot depends on the duplicates that were dropped")
+	}
+	if got, want := snapshot([]string{"plain"}, FirstWins), snapshotHash(files["plain"]); got != want {
+		t.Errorf("snapshot of a single file = %s, want its diff snapshot %s", got, want)
+	}
+	// entries matched against another layer are reported with another source
+	if snapshot([]string{"plain", "copy"}, FirstWins) == snapshot([]string{"copy", "plain"}, FirstWins) {
+		t.Errorf("snapshot does not depend on the source of the entries")
+	}
+}
+
+func TestParseDuplicatePolicy(t *testing.T) {
+	for in, want := range map[string]DuplicatePolicy{"": FirstWins, "first": FirstWins, "LAST": LastWins, "fail": FailOnDuplicate, "merge": MergeDuplicates} {
+		if got, err := ParseDuplicatePolicy(in); err != nil || got != want {
+			t.Errorf("ParseDuplicatePolicy(%q) = %q, %v, want %q", in, got, err, want)
+		}
+	}
+	if _, err := ParseDuplicatePolicy("newest"); err == nil {
+		t.Errorf("ParseDuplicatePolicy(newest) expected an error")
+	}
+}
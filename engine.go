@@ -0,0 +1,303 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"runtime"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"golang.org/x/sync/errgroup"
+)
+
+// EngineResult what an engine found. Ordered engines return the records by
+// file path and record index, the others in the order their workers finished them.
+type EngineResult struct {
+	Valid         []LocationData
+	Invalid       []LocationData
+	Unprocessable []string
+}
+
+// add appends the outcome of one file
+func (r *EngineResult) add(result FileResult) {
+	if result.Err != nil {
+		r.Unprocessable = append(r.Unprocessable, result.Path)
+		return
+	}
+	for _, record := range result.Records {
+		if record.Valid {
+			r.Valid = append(r.Valid, record.Record)
+		} else {
+			r.Invalid = append(r.Invalid, record.Record)
+		}
+	}
+}
+
+// Engine one strategy to validate every file of a folder. Implementations
+// load the reference data once when they start and validate the whole run
+// against it, and return ctx.Err() when ctx is done before they finish.
+type Engine interface {
+	Process(ctx context.Context, tmpFolder string, helpers HelperUtils) (EngineResult, error)
+}
+
+// engineSpec an engine that can be picked by name, pooled ones take a worker count
+type engineSpec struct {
+	name   string
+	pooled bool
+	new    func(workers int, ordered bool) Engine
+}
+
+// engines every Engine, by name
+var engines = []engineSpec{
+	{"stream", true, func(workers int, ordered bool) Engine {
+		return StreamEngine{RunOptions{Ordered: ordered, Workers: workers}}
+	}},
+	{"mutex", false, func(_ int, ordered bool) Engine { return legacyEngine{ProcessFilesWith, ordered} }},
+	{"channels", false, func(_ int, ordered bool) Engine { return legacyEngine{ProcessFilesWithoutMutexWith, ordered} }},
+	{"sharded", true, func(workers int, ordered bool) Engine { return ShardedEngine{Workers: workers, Ordered: ordered} }},
+	{"pipeline", true, func(workers int, ordered bool) Engine { return PipelineEngine{Workers: workers, Ordered: ordered} }},
+}
+
+// EngineNames names NewEngine accepts
+func EngineNames() []string {
+	names := make([]string, len(engines))
+	for i, spec := range engines {
+		names[i] = spec.name
+	}
+	return names
+}
+
+// NewEngine engine called name, workers bounds the files in flight of the
+// pooled engines, 0 uses GOMAXPROCS. With ordered it returns the records by
+// file path and record index.
+func NewEngine(name string, workers int, ordered bool) (Engine, error) {
+	for _, spec := range engines {
+		if spec.name == name {
+			return spec.new(workers, ordered), nil
+		}
+	}
+	return nil, fmt.Errorf("unknown engine %q, want one of %s", name, strings.Join(EngineNames(), ", "))
+}
+
+func workerCount(workers int) int {
+	if workers <= 0 {
+		return runtime.GOMAXPROCS(0)
+	}
+	return workers
+}
+
+// StreamEngine forEachFileResult behind the Engine interface
+type StreamEngine struct {
+	Options RunOptions
+}
+
+func (e StreamEngine) Process(ctx context.Context, tmpFolder string, helpers HelperUtils) (EngineResult, error) {
+	var result EngineResult
+	err := forEachFileResult(ctx, tmpFolder, helpers, e.Options, func(file FileResult) error {
+		result.add(file)
+		return nil
+	})
+	if err != nil {
+		return EngineResult{}, err
+	}
+	return result, nil
+}
+
+// legacyEngine ProcessFilesWith or ProcessFilesWithoutMutexWith behind the
+// Engine interface. They cannot be stopped, ctx is only checked before and after.
+type legacyEngine struct {
+	process func(string, HelperUtils, bool) ([]LocationData, []LocationData, []string)
+	ordered bool
+}
+
+func (e legacyEngine) Process(ctx context.Context, tmpFolder string, helpers HelperUtils) (EngineResult, error) {
+	if err := ctx.Err(); err != nil {
+		return EngineResult{}, err
+	}
+	valid, invalid, unprocessable := e.process(tmpFolder, helpers, e.ordered)
+	if err := ctx.Err(); err != nil {
+		return EngineResult{}, err
+	}
+	return EngineResult{Valid: valid, Invalid: invalid, Unprocessable: unprocessable}, nil
+}
+
+// ShardedEngine gives every worker its own result buffers and merges them
+// once all files are done, so the workers never share a lock. Files are
+// handed out through an atomic counter.
+type ShardedEngine struct {
+	Workers int  // 0 uses GOMAXPROCS
+	Ordered bool // order by file path, every file then gets its own buffer
+}
+
+func (e ShardedEngine) Process(ctx context.Context, tmpFolder string, helpers HelperUtils) (EngineResult, error) {
+	ref := loadedReference()
+	files, err := helpers.getAllFiles(tmpFolder)
+	if err != nil {
+		return EngineResult{}, err
+	}
+	var perFile []FileResult // only written at the index of the file
+	if e.Ordered {
+		files = slices.Clone(files)
+		slices.Sort(files)
+		perFile = make([]FileResult, len(files))
+	}
+
+	shards := make([]EngineResult, workerCount(e.Workers))
+	var next atomic.Int64
+	var wg sync.WaitGroup
+	for i := range shards {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			var local EngineResult // written back once, not shared while working
+			for ctx.Err() == nil {
+				n := int(next.Add(1)) - 1
+				if n >= len(files) {
+					break
+				}
+				result := ref.validateFile(files[n], helpers)
+				if e.Ordered {
+					perFile[n] = result
+					continue
+				}
+				local.add(result)
+			}
+			shards[i] = local
+		}()
+	}
+	wg.Wait()
+
+	if err := ctx.Err(); err != nil {
+		return EngineResult{}, err
+	}
+	var result EngineResult
+	for _, file := range perFile {
+		result.add(file)
+	}
+	for _, shard := range shards {
+		result.Valid = append(result.Valid, shard.Valid...)
+		result.Invalid = append(result.Invalid, shard.Invalid...)
+		result.Unprocessable = append(result.Unprocessable, shard.Unprocessable...)
+	}
+	return result, nil
+}
+
+// PipelineEngine runs reading, parsing and validation as separate stages of
+// Workers goroutines each, connected by channels and supervised by an
+// errgroup. The first two stages split what helpers.loadDataFunc does in one
+// call into helpers.readFunc and helpers.parseFunc.
+type PipelineEngine struct {
+	Workers int  // per stage, 0 uses GOMAXPROCS
+	Ordered bool // order by file path once all files are validated
+}
+
+// pipelineFile a file on its way through the stages
+type pipelineFile struct {
+	path   string
+	data   []byte
+	cities []LocationData
+	load   time.Duration // reading and parsing
+	err    error
+}
+
+func (e PipelineEngine) Process(ctx context.Context, tmpFolder string, helpers HelperUtils) (EngineResult, error) {
+	ref := loadedReference()
+	files, err := helpers.getAllFiles(tmpFolder)
+	if err != nil {
+		return EngineResult{}, err
+	}
+
+	workers := workerCount(e.Workers)
+	g, stageCtx := errgroup.WithContext(ctx)
+	paths := make(chan pipelineFile)
+	read := make(chan pipelineFile, workers)
+	parsed := make(chan pipelineFile, workers)
+	results := make(chan FileResult, workers)
+
+	g.Go(func() error {
+		defer close(paths)
+		for _, path := range files {
+			select {
+			case paths <- pipelineFile{path: path}:
+			case <-stageCtx.Done():
+				return stageCtx.Err()
+			}
+		}
+		return nil
+	})
+	pipelineStage(stageCtx, g, workers, paths, read, func(file pipelineFile) pipelineFile {
+		metrics.filesProcessed.Inc()
+		start := time.Now()
+		file.data, file.err = helpers.readFunc(file.path)
+		file.load = time.Since(start)
+		return file
+	})
+	pipelineStage(stageCtx, g, workers, read, parsed, func(file pipelineFile) pipelineFile {
+		if file.err == nil {
+			start := time.Now()
+			file.cities, file.err = helpers.parseFunc(file.path, file.data)
+			file.data = nil
+			file.load += time.Since(start)
+		}
+		metrics.loadSeconds.Observe(file.load.Seconds())
+		if file.err != nil {
+			metrics.observeUnprocessable(file.err)
+		}
+		return file
+	})
+	pipelineStage(stageCtx, g, workers, parsed, results, func(file pipelineFile) FileResult {
+		if file.err != nil {
+			return FileResult{Path: file.path, Err: file.err}
+		}
+		return ref.validateCities(file.path, file.cities, helpers)
+	})
+
+	var result EngineResult
+	var collected []FileResult
+	for file := range results {
+		if e.Ordered {
+			collected = append(collected, file)
+			continue
+		}
+		result.add(file)
+	}
+	if err := g.Wait(); err != nil {
+		return EngineResult{}, err
+	}
+	if err := ctx.Err(); err != nil {
+		return EngineResult{}, err
+	}
+	slices.SortFunc(collected, func(a, b FileResult) int { return strings.Compare(a.Path, b.Path) })
+	for _, file := range collected {
+		result.add(file)
+	}
+	return result, nil
+}
+
+// pipelineStage starts n goroutines in g that pass every item of in through fn
+// to out, and closes out once they are all done
+func pipelineStage[In, Out any](ctx context.Context, g *errgroup.Group, n int, in <-chan In, out chan<- Out, fn func(In) Out) {
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		g.Go(func() error {
+			defer wg.Done()
+			for item := range in {
+				select {
+				case out <- fn(item):
+				case <-ctx.Done():
+					return ctx.Err()
+				}
+			}
+			return nil
+		})
+	}
+	g.Go(func() error {
+		wg.Wait()
+		close(out)
+		return nil
+	})
+}
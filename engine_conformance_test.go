@@ -0,0 +1,310 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+// conformanceCase one situation every Engine has to handle the same way
+type conformanceCase struct {
+	name string
+	// setup loads the reference data and returns the folder to process and
+	// what a run over it must find, want nil when only its size is known
+	setup     func(t *testing.T) (dir string, want *EngineResult, size DatasetSummary)
+	cancelled func(calls int64) bool                                 // cancel the run on this call of getUniqueKeyFunc
+	read      func(path string) ([]byte, error)                      // replaces readFunc
+	parse     func(path string, data []byte) ([]LocationData, error) // replaces parseFunc
+	wantErr   error
+}
+
+func conformanceCases() []conformanceCase {
+	return []conformanceCase{
+		{
+			name: "exact results",
+			setup: func(t *testing.T) (string, *EngineResult, DatasetSummary) {
+				dir := t.TempDir()
+				reference := filepath.Join(t.TempDir(), "cities.json")
+				writeReferenceFile(t, reference, []LocationData{oslo, rabat})
+				loadConformanceReference(t, reference)
+
+				wrongProvince := oslo
+				wrongProvince.Province = "Viken"
+				writeReferenceFile(t, filepath.Join(dir, "a.json"), []LocationData{oslo, wrongProvince})
+				writeReferenceFile(t, filepath.Join(dir, "b.json"), []LocationData{rabat, elAaiun, rabat})
+				writeReferenceFile(t, filepath.Join(dir, "empty.json"), []LocationData{})
+				writeConformanceFile(t, filepath.Join(dir, "broken.json"), `[{"city": "Oslo"`)
+				writeConformanceFile(t, filepath.Join(dir, "notes.txt"), "not a city file")
+				return dir, &EngineResult{
+					Valid:         []LocationData{oslo, rabat, rabat},
+					Invalid:       []LocationData{elAaiun, wrongProvince},
+					Unprocessable: []string{filepath.Join(dir, "broken.json")},
+				}, DatasetSummary{}
+			},
+		},
+		{
+			name: "tmp fixture",
+			setup: func(t *testing.T) (string, *EngineResult, DatasetSummary) {
+				loadConformanceReference(t, "cities.json")
+				return "tmp", nil, DatasetSummary{Valid: 800, Invalid: 209, Corrupt: 2}
+			},
+		},
+		{
+			name: "empty directory",
+			setup: func(t *testing.T) (string, *EngineResult, DatasetSummary) {
+				loadConformanceReference(t, "cities.json")
+				return t.TempDir(), &EngineResult{}, DatasetSummary{}
+			},
+		},
+		{
+			name: "all corrupt",
+			setup: func(t *testing.T) (string, *EngineResult, DatasetSummary) {
+				return generateConformanceDataset(t, DatasetOptions{Files: 20, RecordsPerFile: 5, CorruptRatio: 1, Seed: 1})
+			},
+		},
+		{
+			name: "generated mix",
+			setup: func(t *testing.T) (string, *EngineResult, DatasetSummary) {
+				return generateConformanceDataset(t, DatasetOptions{Files: 60, RecordsPerFile: 15, InvalidRatio: 0.3, CorruptRatio: 0.1, Seed: 2})
+			},
+		},
+		{
+			name: "huge files",
+			setup: func(t *testing.T) (string, *EngineResult, DatasetSummary) {
+				return generateConformanceDataset(t, DatasetOptions{Files: 3, RecordsPerFile: 2000, InvalidRatio: 0.2, Seed: 3})
+			},
+		},
+		{
+			name: "injected loader",
+			setup: func(t *testing.T) (string, *EngineResult, DatasetSummary) {
+				reference := filepath.Join(t.TempDir(), "cities.json")
+				writeReferenceFile(t, reference, []LocationData{oslo, rabat})
+				loadConformanceReference(t, reference)
+				// engines must read and parse through the helpers, not the files themselves
+				dir := t.TempDir()
+				writeConformanceFile(t, filepath.Join(dir, "a.json"), "not JSON")
+				writeConformanceFile(t, filepath.Join(dir, "b.json"), "not JSON")
+				return dir, &EngineResult{Valid: []LocationData{rabat}, Invalid: []LocationData{elAaiun}}, DatasetSummary{}
+			},
+			read: func(path string) ([]byte, error) {
+				return []byte(filepath.Base(path)), nil
+			},
+			parse: func(path string, data []byte) ([]LocationData, error) {
+				if string(data) != filepath.Base(path) {
+					return nil, fmt.Errorf("%s: parsed %q, not what was read", path, data)
+				}
+				if string(data) == "a.json" {
+					return []LocationData{rabat}, nil
+				}
+				return []LocationData{elAaiun}, nil
+			},
+		},
+		{
+			name: "cancelled before the run",
+			setup: func(t *testing.T) (string, *EngineResult, DatasetSummary) {
+				loadConformanceReference(t, "cities.json")
+				return "tmp", nil, DatasetSummary{}
+			},
+			cancelled: func(calls int64) bool { return calls == 0 },
+			wantErr:   context.Canceled,
+		},
+		{
+			name: "cancelled during the run",
+			setup: func(t *testing.T) (string, *EngineResult, DatasetSummary) {
+				return generateConformanceDataset(t, DatasetOptions{Files: 200, RecordsPerFile: 10, Seed: 4})
+			},
+			cancelled: func(calls int64) bool { return calls == 50 },
+			wantErr:   context.Canceled,
+		},
+	}
+}
+
+func loadConformanceReference(t *testing.T, path string) {
+	t.Helper()
+	if _, err := loadAuthenticCities(path, loadDataToStruct, GetUniqueKey, FirstWins); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func writeConformanceFile(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func generateConformanceDataset(t *testing.T, opts DatasetOptions) (string, *EngineResult, DatasetSummary) {
+	t.Helper()
+	loadConformanceReference(t, "cities.json")
+	reference, err := loadDataToStruct("cities.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir := t.TempDir()
+	summary, err := GenerateDataset(dir, reference, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return dir, nil, summary
+}
+
+// namedEngine an engine configuration under test
+type namedEngine struct {
+	name    string
+	engine  Engine
+	ordered bool
+}
+
+// conformanceEngines every engine unordered and ordered, the pooled ones with
+// one and several workers
+func conformanceEngines() []namedEngine {
+	var all []namedEngine
+	for _, spec := range engines {
+		for _, ordered := range []bool{false, true} {
+			name := spec.name
+			if ordered {
+				name += "/ordered"
+			}
+			if !spec.pooled {
+				all = append(all, namedEngine{name, spec.new(0, ordered), ordered})
+				continue
+			}
+			for _, workers := range []int{1, 4} {
+				all = append(all, namedEngine{fmt.Sprintf("%s/workers=%d", name, workers), spec.new(workers, ordered), ordered})
+			}
+		}
+	}
+	return all
+}
+
+// TestEngineConformance runs every engine through the same cases, a new
+// engine only has to be added to engines to be covered
+func TestEngineConformance(t *testing.T) {
+	for _, tt := range conformanceCases() {
+		t.Run(tt.name, func(t *testing.T) {
+			dir, want, size := tt.setup(t)
+			if want == nil && tt.wantErr == nil && size == (DatasetSummary{}) {
+				t.Fatal("case has no expectation")
+			}
+
+			var reference, orderedReference *EngineResult
+			for _, e := range conformanceEngines() {
+				t.Run(e.name, func(t *testing.T) {
+					ctx, cancel := context.WithCancel(context.Background())
+					defer cancel()
+					helpers := HelperUtils{readData, parseCityFile, GetUniqueKey, hardCheck, getAllFiles}
+					if tt.read != nil {
+						helpers.readFunc = tt.read
+					}
+					if tt.parse != nil {
+						helpers.parseFunc = tt.parse
+					}
+					if tt.cancelled != nil {
+						var calls atomic.Int64
+						if tt.cancelled(0) {
+							cancel()
+						}
+						helpers.getUniqueKeyFunc = func(city LocationData) Key {
+							if tt.cancelled(calls.Add(1)) {
+								cancel()
+							}
+							return GetUniqueKey(city)
+						}
+					}
+
+					got, err := e.engine.Process(ctx, dir, helpers)
+					if !errors.Is(err, tt.wantErr) {
+						t.Fatalf("Process() error = %v, want %v", err, tt.wantErr)
+					}
+					if err != nil {
+						if !reflect.DeepEqual(got, EngineResult{}) {
+							t.Errorf("Process() returned results with error %v", err)
+						}
+						return
+					}
+
+					// ordered engines must also agree on the order
+					if e.ordered {
+						if orderedReference == nil {
+							// sortedResult below sorts the slices of got in place
+							orderedReference = &EngineResult{slices.Clone(got.Valid), slices.Clone(got.Invalid), slices.Clone(got.Unprocessable)}
+						} else if !reflect.DeepEqual(got, *orderedReference) {
+							t.Errorf("Process() order differs from the other ordered engines")
+						}
+					}
+
+					got = sortedResult(got)
+					if want != nil && !reflect.DeepEqual(got, sortedResult(*want)) {
+						t.Errorf("Process() = %+v, want %+v", got, *want)
+					}
+					if size != (DatasetSummary{}) {
+						gotSize := DatasetSummary{Valid: len(got.Valid), Invalid: len(got.Invalid), Corrupt: len(got.Unprocessable)}
+						if gotSize != (DatasetSummary{Valid: size.Valid, Invalid: size.Invalid, Corrupt: size.Corrupt}) {
+							t.Errorf("Process() found %+v, want %+v", gotSize, size)
+						}
+					}
+					// engines that agree on the counts must also agree on the records
+					if reference == nil {
+						reference = &got
+					} else if !reflect.DeepEqual(got, *reference) {
+						t.Errorf("Process() records differ from the other engines")
+					}
+				})
+			}
+		})
+	}
+}
+
+// TestEngineConformanceConcurrent runs every engine several times at once
+// while the reference data is reloaded, for go test -race
+func TestEngineConformanceConcurrent(t *testing.T) {
+	loadConformanceReference(t, "cities.json")
+	helpers := HelperUtils{readData, parseCityFile, GetUniqueKey, hardCheck, getAllFiles}
+	want, err := StreamEngine{RunOptions{Ordered: true}}.Process(context.Background(), "tmp", helpers)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want = sortedResult(want)
+
+	for _, e := range conformanceEngines() {
+		t.Run(e.name, func(t *testing.T) {
+			var wg sync.WaitGroup
+			errs := make(chan error, 4)
+			for i := 0; i < cap(errs); i++ {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					got, err := e.engine.Process(context.Background(), "tmp", helpers)
+					if err == nil && !reflect.DeepEqual(sortedResult(got), want) {
+						err = errors.New("results differ from a single run")
+					}
+					errs <- err
+				}()
+			}
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for i := 0; i < 3; i++ {
+					if _, err := loadAuthenticCities("cities.json", loadDataToStruct, GetUniqueKey, FirstWins); err != nil {
+						t.Error(err)
+					}
+				}
+			}()
+			wg.Wait()
+			close(errs)
+
+			for err := range errs {
+				if err != nil {
+					t.Error(err)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"reflect"
+	"sort"
+	"testing"
+)
+
+func TestNewEngine(t *testing.T) {
+	tests := []struct {
+		name    string
+		ordered bool
+		want    Engine
+		wantErr bool
+	}{
+		{name: "stream", ordered: true, want: StreamEngine{RunOptions{Ordered: true, Workers: 3}}},
+		{name: "stream", want: StreamEngine{RunOptions{Workers: 3}}},
+		{name: "sharded", ordered: true, want: ShardedEngine{Workers: 3, Ordered: true}},
+		{name: "pipeline", want: PipelineEngine{Workers: 3}},
+		{name: "fastest", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := NewEngine(tt.name, 3, tt.ordered)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("NewEngine() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("NewEngine() = %#v, want %#v", got, tt.want)
+			}
+		})
+	}
+}
+
+// sortedResult r with every slice sorted, to compare engines that do not order their results
+func sortedResult(r EngineResult) EngineResult {
+	byFields := func(cities []LocationData) {
+		sort.Slice(cities, func(i, j int) bool {
+			a, b := cities[i], cities[j]
+			if a.Name != b.Name {
+				return a.Name < b.Name
+			}
+			if a.Country != b.Country {
+				return a.Country < b.Country
+			}
+			if a.Geo != b.Geo {
+				return a.Geo < b.Geo
+			}
+			return a.Province+a.Latitude+a.Longitude < b.Province+b.Latitude+b.Longitude
+		})
+	}
+	byFields(r.Valid)
+	byFields(r.Invalid)
+	sort.Strings(r.Unprocessable)
+	return r
+}
@@ -0,0 +1,184 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+	"time"
+)
+
+// RecordResult outcome of one record, Index is its position in the file
+type RecordResult struct {
+	Index  int          `json:"index"`
+	Record LocationData `json:"record"`
+	Valid  bool         `json:"valid"`
+}
+
+// FileResult outcome of validating a single file, Err is set when it could not be loaded
+type FileResult struct {
+	Path    string
+	Records []RecordResult
+	Err     error
+}
+
+// Invalid records of the file that failed validation
+func (r FileResult) Invalid() []RecordResult {
+	var invalid []RecordResult
+	for _, record := range r.Records {
+		if !record.Valid {
+			invalid = append(invalid, record)
+		}
+	}
+	return invalid
+}
+
+// validateFile validates the records of path against ref
+func (ref *referenceData) validateFile(path string, helpers HelperUtils) FileResult {
+	result := FileResult{Path: path}
+	metrics.filesProcessed.Inc()
+
+	start := time.Now()
+	cities, err := helpers.loadDataFunc(path)
+	metrics.loadSeconds.Observe(time.Since(start).Seconds())
+	if err != nil {
+		metrics.observeUnprocessable(err)
+		result.Err = err
+		return result
+	}
+	return ref.validateCities(path, cities, helpers)
+}
+
+// validateCities validates the already loaded records of path against ref
+func (ref *referenceData) validateCities(path string, cities []LocationData, helpers HelperUtils) FileResult {
+	result := FileResult{Path: path}
+	start := time.Now()
+	result.Records = make([]RecordResult, len(cities))
+	for i, element := range cities {
+		verifyData, ok := ref.lookup(helpers.getUniqueKeyFunc(element))
+		valid := ok && helpers.hardValidateFunc(verifyData, element)
+		result.Records[i] = RecordResult{Index: i, Record: element, Valid: valid}
+		metrics.observeRecord(ref.countryLabel(element.Country), ok, valid)
+	}
+	metrics.validateSeconds.Observe(time.Since(start).Seconds())
+	return result
+}
+
+// ProcessFileResults validates every file of tmpFolder concurrently and keeps
+// the outcome per file, ordered by file path.
+func ProcessFileResults(tmpFolder string, helpers HelperUtils) ([]FileResult, error) {
+	return ProcessFileResultsWith(tmpFolder, helpers, RunOptions{Ordered: true})
+}
+
+// ProcessFilesOrdered is ProcessFiles returning the records ordered by file
+// path and record index, so two runs over the same files give the same output.
+func ProcessFilesOrdered(tmpFolder string, helpers HelperUtils) ([]LocationData, []LocationData, []string) {
+	results, err := ProcessFileResults(tmpFolder, helpers)
+	if err != nil {
+		slog.Error("reading tmp folder", "dir", tmpFolder, "err", err)
+		return nil, nil, nil
+	}
+	return splitResults(results)
+}
+
+// RunOptions how a run orders its results and where it may take them from
+// instead of validating a file
+type RunOptions struct {
+	Ordered    bool          // order by file path, otherwise files come in the order they finish
+	Workers    int           // files validated at the same time, 0 uses GOMAXPROCS
+	Cache      *ResultCache  // results of earlier runs, keyed by content
+	Checkpoint *Checkpointer // progress of this run, possibly resumed
+	Progress   *ProgressReporter
+
+	reference *referenceData // loaded once when the run starts
+}
+
+// ProcessFileResultsWith is ProcessFileResults with a result cache and checkpointing
+func ProcessFileResultsWith(tmpFolder string, helpers HelperUtils, opts RunOptions) ([]FileResult, error) {
+	var results []FileResult
+	err := forEachFileResult(context.Background(), tmpFolder, helpers, opts, func(result FileResult) error {
+		results = append(results, result)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return results, nil
+}
+
+// processFile validates path in a worker and counts it as done
+func (opts RunOptions) processFile(path string, helpers HelperUtils) FileResult {
+	result := opts.validateFile(path, helpers)
+	opts.Progress.fileDone(len(result.Records))
+
+	logger := slog.With("file", path)
+	if result.Err != nil {
+		logger.Warn("unprocessable file", "err", result.Err)
+	} else {
+		logger.Debug("validated file", "records", len(result.Records), "invalid", len(result.Invalid()))
+	}
+	return result
+}
+
+// validateFile validateFile going through the checkpoint and the cache first
+func (opts RunOptions) validateFile(path string, helpers HelperUtils) FileResult {
+	if opts.Cache == nil && opts.Checkpoint == nil {
+		return opts.reference.validateFile(path, helpers)
+	}
+
+	checksum, err := fileChecksum(path)
+	if err != nil {
+		return FileResult{Path: path, Err: err}
+	}
+
+	snapshot := opts.reference.snapshot
+	if result, ok := opts.Checkpoint.lookup(path, checksum); ok {
+		opts.reference.observeCached(result, "checkpoint", helpers)
+		opts.Cache.store(checksum, snapshot, result)
+		return result
+	}
+	result, ok := opts.Cache.lookup(path, checksum, snapshot)
+	if ok {
+		opts.reference.observeCached(result, "cache", helpers)
+	} else {
+		result = opts.reference.validateFile(path, helpers)
+		opts.Cache.store(checksum, snapshot, result)
+	}
+	opts.Checkpoint.done(path, checksum, result)
+	return result
+}
+
+// observeCached counts a result taken from source instead of validating the
+// file in the file and record metrics, as validateFile would have
+func (ref *referenceData) observeCached(result FileResult, source string, helpers HelperUtils) {
+	metrics.filesProcessed.Inc()
+	metrics.filesCached.Inc(source)
+	if result.Err != nil {
+		metrics.observeUnprocessable(result.Err)
+		return
+	}
+	for _, record := range result.Records {
+		_, found := ref.lookup(helpers.getUniqueKeyFunc(record.Record))
+		metrics.observeRecord(ref.countryLabel(record.Record.Country), found, record.Valid)
+	}
+}
+
+// splitResults flattens per file results into the valid records, invalid
+// records and unprocessable files that ProcessFiles returns
+func splitResults(results []FileResult) ([]LocationData, []LocationData, []string) {
+	var successfullyValidated, unsuccessfullyValidated []LocationData
+	var unprocessableFiles []string
+
+	for _, result := range results {
+		if result.Err != nil {
+			unprocessableFiles = append(unprocessableFiles, result.Path)
+			continue
+		}
+		for _, record := range result.Records {
+			if record.Valid {
+				successfullyValidated = append(successfullyValidated, record.Record)
+			} else {
+				unsuccessfullyValidated = append(unsuccessfullyValidated, record.Record)
+			}
+		}
+	}
+	return successfullyValidated, unsuccessfullyValidated, unprocessableFiles
+}
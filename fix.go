@@ -0,0 +1,314 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// defaultFixConfidence suggestions below this confidence are never applied
+const defaultFixConfidence = 0.9
+
+// FixOptions where fixed files go and how sure a match has to be
+type FixOptions struct {
+	InPlace       bool   // overwrite the input, keeping a copy with BackupSuffix
+	OutputDir     string // write fixed files here instead
+	BackupSuffix  string
+	MinConfidence float64
+}
+
+// RecordFix one record replaced by its reference entry
+type RecordFix struct {
+	Index      int           `json:"index"`
+	Source     string        `json:"source,omitempty"`
+	Confidence float64       `json:"confidence"`
+	Fields     []FieldChange `json:"fields"`
+}
+
+// FileFix change log of a single file
+type FileFix struct {
+	File    string      `json:"file"`
+	Output  string      `json:"output,omitempty"` // empty when nothing was written
+	Backup  string      `json:"backup,omitempty"`
+	Fixed   []RecordFix `json:"fixed"`
+	Skipped []int       `json:"skipped"` // invalid records without a confident match
+}
+
+// fixRecord returns the reference entry that should replace record. Only
+// records whose name and country already match the reference are fixed, so
+// drifting coordinates, a broken geo or missing icons are corrected but a
+// record is never turned into a different city.
+func (reference *referenceData) fixRecord(record LocationData, minConfidence float64) (LocationData, Suggestion, bool) {
+	suggestion, ok := reference.suggest(record)
+	if !ok || suggestion.Confidence < minConfidence {
+		return record, suggestion, false
+	}
+
+	ref := suggestion.Reference
+	if nameNormalization.Apply(ref.Name) != nameNormalization.Apply(record.Name) ||
+		nameNormalization.Apply(ref.Country) != nameNormalization.Apply(record.Country) {
+		return record, suggestion, false
+	}
+	return ref, suggestion, true
+}
+
+// FixFile rewrites the invalid records of path that have a confident reference
+// match. Records keep their position and only the changed values are replaced.
+func FixFile(path string, opts FixOptions, helpers HelperUtils) (FileFix, error) {
+	return loadedReference().fixFile(path, opts, helpers)
+}
+
+func (ref *referenceData) fixFile(path string, opts FixOptions, helpers HelperUtils) (FileFix, error) {
+	result := FileFix{File: path, Fixed: []RecordFix{}, Skipped: []int{}}
+
+	data, err := readData(path)
+	if err != nil {
+		return result, err
+	}
+	records, err := arrayElements(data)
+	if err != nil {
+		return result, err
+	}
+
+	var edits []jsonEdit
+	for i, span := range records {
+		message := data[span.start:span.end]
+		var record LocationData
+		if err := json.Unmarshal(message, &record); err != nil {
+			return result, fmt.Errorf("record %d: %w", i, err)
+		}
+
+		verifyData, ok := ref.lookup(helpers.getUniqueKeyFunc(record))
+		if ok && helpers.hardValidateFunc(verifyData, record) {
+			continue
+		}
+
+		fixed, suggestion, ok := ref.fixRecord(record, opts.MinConfidence)
+		if !ok {
+			result.Skipped = append(result.Skipped, i)
+			continue
+		}
+		fields := changedFields(record, fixed)
+		patched, err := patchRecord(message, fields)
+		if err != nil {
+			return result, fmt.Errorf("record %d: %w", i, err)
+		}
+		span.text = patched
+		edits = append(edits, span)
+		result.Fixed = append(result.Fixed, RecordFix{
+			Index:      i,
+			Source:     suggestion.Source,
+			Confidence: suggestion.Confidence,
+			Fields:     fields,
+		})
+	}
+
+	if len(result.Fixed) == 0 {
+		return result, nil
+	}
+
+	result.Output = path
+	if !opts.InPlace {
+		if err := os.MkdirAll(opts.OutputDir, 0o755); err != nil {
+			return result, err
+		}
+		result.Output = filepath.Join(opts.OutputDir, filepath.Base(path))
+	} else {
+		result.Backup = path + opts.BackupSuffix
+		if err := writeFileAtomic(result.Backup, data); err != nil {
+			return result, err
+		}
+	}
+
+	if err := writeFileAtomic(result.Output, applyEdits(data, edits)); err != nil {
+		return result, err
+	}
+	return result, nil
+}
+
+// jsonEdit replaces data[start:end] with text
+type jsonEdit struct {
+	start, end int
+	text       []byte
+}
+
+// applyEdits returns data with the edits made, they are ordered and do not overlap
+func applyEdits(data []byte, edits []jsonEdit) []byte {
+	var out bytes.Buffer
+	pos := 0
+	for _, edit := range edits {
+		out.Write(data[pos:edit.start])
+		out.Write(edit.text)
+		pos = edit.end
+	}
+	out.Write(data[pos:])
+	return out.Bytes()
+}
+
+// arrayElements where every element of the JSON array in data starts and ends
+func arrayElements(data []byte) ([]jsonEdit, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	if tok, err := dec.Token(); err != nil || tok != json.Delim('[') {
+		return nil, errors.New("file is not a JSON array")
+	}
+	var elements []jsonEdit
+	for dec.More() {
+		var value json.RawMessage
+		if err := dec.Decode(&value); err != nil {
+			return nil, err
+		}
+		end := int(dec.InputOffset())
+		elements = append(elements, jsonEdit{start: end - len(value), end: end})
+	}
+	if _, err := dec.Token(); err != nil {
+		return nil, err
+	}
+	if _, err := dec.Token(); err != io.EOF {
+		return nil, errors.New("data after the JSON array")
+	}
+	return elements, nil
+}
+
+// patchRecord sets the changed fields in the JSON object record. Only their
+// values are replaced, keys, order and layout of the original are kept.
+// Fields the record lacks are appended.
+func patchRecord(record []byte, fields []FieldChange) ([]byte, error) {
+	type member struct {
+		key   string
+		value jsonEdit
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(record))
+	if tok, err := dec.Token(); err != nil || tok != json.Delim('{') {
+		return nil, errors.New("record is not a JSON object")
+	}
+	var members []member
+	for dec.More() {
+		tok, err := dec.Token()
+		if err != nil {
+			return nil, err
+		}
+		var value json.RawMessage
+		if err := dec.Decode(&value); err != nil {
+			return nil, err
+		}
+		end := int(dec.InputOffset())
+		members = append(members, member{tok.(string), jsonEdit{start: end - len(value), end: end}})
+	}
+	// missing fields go right after the last member, or into the empty object
+	insertAt := bytes.LastIndexByte(record, '}')
+	if len(members) > 0 {
+		insertAt = members[len(members)-1].value.end
+	}
+
+	var edits []jsonEdit
+	var appended bytes.Buffer
+	for _, field := range fields {
+		value, err := json.Marshal(field.New)
+		if err != nil {
+			return nil, err
+		}
+		found := false
+		for i := range members {
+			if strings.EqualFold(members[i].key, field.Field) {
+				members[i].value.text, found = value, true
+			}
+		}
+		if !found {
+			if len(members) > 0 || appended.Len() > 0 {
+				appended.WriteString(", ")
+			}
+			key, _ := json.Marshal(field.Field)
+			appended.Write(key)
+			appended.WriteString(": ")
+			appended.Write(value)
+		}
+	}
+	for _, m := range members {
+		if m.value.text != nil {
+			edits = append(edits, m.value)
+		}
+	}
+	if appended.Len() > 0 {
+		edits = append(edits, jsonEdit{start: insertAt, end: insertAt, text: appended.Bytes()})
+	}
+	return applyEdits(record, edits), nil
+}
+
+// runFix handles `fix [flags] [dir]`, the change log of every file goes to stdout
+func runFix(args []string) int {
+	fs := flag.NewFlagSet("fix", flag.ExitOnError)
+	applyNormalization := addNormalizationFlags(fs)
+	applyLogging := addLogFlags(fs)
+	reference := addReferenceFlags(fs)
+	inPlace := fs.Bool("in-place", false, "overwrite the input files, keeping a backup")
+	backupSuffix := fs.String("backup-suffix", ".bak", "suffix of the backup written by -in-place")
+	outputDir := fs.String("out", "", "write fixed files to this directory")
+	minConfidence := fs.Float64("min-confidence", defaultFixConfidence, "minimum suggestion confidence to apply a fix")
+	_ = fs.Parse(args)
+
+	if err := applyLogging(); err != nil {
+		slog.Error("parsing flags", "err", err)
+		return 2
+	}
+	if err := applyNormalization(); err != nil {
+		slog.Error("parsing flags", "err", err)
+		return 2
+	}
+	policy, err := reference.policy()
+	if err != nil {
+		slog.Error("parsing flags", "err", err)
+		return 2
+	}
+	if *inPlace == (*outputDir != "") {
+		slog.Error("parsing flags", "err", "exactly one of -in-place and -out is required")
+		return 2
+	}
+
+	dir := "tmp"
+	if fs.NArg() > 0 {
+		dir = fs.Arg(0)
+	}
+
+	if _, err := loadReferenceLayers(reference.sources(), loadDataToStruct, GetUniqueKey, policy); err != nil {
+		slog.Error("loading authentic cities", "err", err)
+		return 1
+	}
+
+	helpers := HelperUtils{readData, parseCityFile, GetUniqueKey, hardCheck, getAllFiles}
+	files, err := helpers.getAllFiles(dir)
+	if err != nil {
+		slog.Error("reading tmp folder", "dir", dir, "err", err)
+		return 1
+	}
+
+	opts := FixOptions{InPlace: *inPlace, OutputDir: *outputDir, BackupSuffix: *backupSuffix, MinConfidence: *minConfidence}
+	logs := make([]FileFix, 0, len(files))
+	status := 0
+
+	ref := loadedReference()
+	for _, file := range files {
+		result, err := ref.fixFile(file, opts, helpers)
+		if err != nil {
+			slog.Error("fixing file", "file", file, "err", err)
+			status = 1
+			continue
+		}
+		logs = append(logs, result)
+	}
+
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(logs); err != nil {
+		slog.Error("writing report", "err", err)
+		return 1
+	}
+	return status
+}
@@ -0,0 +1,156 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const fixCandidate = `[
+    {"city": "Oslo", "country": "Norway", "geo": "59.57, 10.45", "latitude": "59.57", "longitude": "10.45"},
+    {"city": "Rabat", "country": "Morocco", "geo": "34.02, 6.50a", "latitude": "34.021", "longitude": "6.50"},
+    {"city": "Olso", "country": "Norway"}
+]`
+
+func TestFixFile(t *testing.T) {
+	reference := filepath.Join(t.TempDir(), "cities.json")
+	writeReferenceFile(t, reference, []LocationData{oslo, rabat})
+	if _, err := loadReferenceLayers([]string{reference}, loadDataToStruct, GetUniqueKey, FirstWins); err != nil {
+		t.Fatal(err)
+	}
+	helpers := HelperUtils{readData, parseCityFile, GetUniqueKey, hardCheck, getAllFiles}
+
+	tests := []struct {
+		name    string
+		inPlace bool
+	}{
+		{"in place", true},
+		{"output directory", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			path := filepath.Join(dir, "city-1.json")
+			if err := os.WriteFile(path, []byte(fixCandidate), 0o644); err != nil {
+				t.Fatal(err)
+			}
+			opts := FixOptions{InPlace: tt.inPlace, OutputDir: filepath.Join(dir, "fixed"), BackupSuffix: ".bak", MinConfidence: defaultFixConfidence}
+
+			result, err := FixFile(path, opts, helpers)
+			if err != nil {
+				t.Fatalf("FixFile() error = %v", err)
+			}
+
+			if len(result.Fixed) != 1 || result.Fixed[0].Index != 1 {
+				t.Fatalf("FixFile() fixed = %+v, want record 1", result.Fixed)
+			}
+			wantFields := []string{"latitude", "geo"}
+			var gotFields []string
+			for _, field := range result.Fixed[0].Fields {
+				gotFields = append(gotFields, field.Field)
+			}
+			if !reflect.DeepEqual(gotFields, wantFields) {
+				t.Errorf("FixFile() changed fields = %v, want %v", gotFields, wantFields)
+			}
+			if !reflect.DeepEqual(result.Skipped, []int{2}) {
+				t.Errorf("FixFile() skipped = %v, want [2]", result.Skipped)
+			}
+
+			wantOutput := path
+			if !tt.inPlace {
+				wantOutput = filepath.Join(dir, "fixed", "city-1.json")
+			}
+			if result.Output != wantOutput {
+				t.Errorf("FixFile() output = %q, want %q", result.Output, wantOutput)
+			}
+
+			original := path
+			if tt.inPlace {
+				original = path + ".bak"
+			}
+			if data, err := os.ReadFile(original); err != nil || string(data) != fixCandidate {
+				t.Errorf("original content not preserved in %s: %v", original, err)
+			}
+
+			data, err := os.ReadFile(result.Output)
+			if err != nil {
+				t.Fatal(err)
+			}
+			// only the wrong values change, the rest of the file keeps its bytes
+			wantData := strings.Replace(fixCandidate, `"geo": "34.02, 6.50a", "latitude": "34.021"`, `"geo": "34.02, 6.50", "latitude": "34.02"`, 1)
+			if string(data) != wantData {
+				t.Errorf("fixed file =\n%s\nwant\n%s", data, wantData)
+			}
+			fixed, err := loadDataToStruct(result.Output)
+			if err != nil {
+				t.Fatal(err)
+			}
+			want := []LocationData{oslo, rabat, {Name: "Olso", Country: "Norway"}}
+			if !reflect.DeepEqual(fixed, want) {
+				t.Errorf("fixed records = %+v, want %+v", fixed, want)
+			}
+		})
+	}
+}
+
+func TestFixFileNothingToFix(t *testing.T) {
+	useReference(map[Key]LocationData{GetUniqueKey(oslo): oslo}, "")
+	path := filepath.Join(t.TempDir(), "city-1.json")
+	writeReferenceFile(t, path, []LocationData{oslo})
+
+	result, err := FixFile(path, FixOptions{InPlace: true, BackupSuffix: ".bak", MinConfidence: defaultFixConfidence},
+		HelperUtils{readData, parseCityFile, GetUniqueKey, hardCheck, getAllFiles})
+	if err != nil {
+		t.Fatalf("FixFile() error = %v", err)
+	}
+	if result.Output != "" || len(result.Fixed) != 0 {
+		t.Errorf("FixFile() = %+v, want nothing written", result)
+	}
+	if _, err := os.Stat(path + ".bak"); !os.IsNotExist(err) {
+		t.Errorf("backup written although nothing was fixed")
+	}
+}
+
+func TestPatchRecord(t *testing.T) {
+	tests := []struct {
+		name   string
+		record string
+		fields []FieldChange
+		want   string
+	}{
+		{
+			name:   "value replaced in place",
+			record: "{\n  \"City\": \"Olso\",\n  \"country\": \"Norway\"\n}",
+			fields: []FieldChange{{Field: "city", Old: "Olso", New: "Oslo"}},
+			want:   "{\n  \"City\": \"Oslo\",\n  \"country\": \"Norway\"\n}",
+		},
+		{
+			name:   "missing fields appended after the last member",
+			record: "{\"city\": \"Oslo\" }",
+			fields: []FieldChange{{Field: "country", New: "Norway"}, {Field: "geo", New: "59.57, 10.45"}},
+			want:   "{\"city\": \"Oslo\", \"country\": \"Norway\", \"geo\": \"59.57, 10.45\" }",
+		},
+		{
+			name:   "empty object",
+			record: "{}",
+			fields: []FieldChange{{Field: "city", New: "Oslo"}},
+			want:   "{\"city\": \"Oslo\"}",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := patchRecord([]byte(tt.record), tt.fields)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(got) != tt.want {
+				t.Errorf("patchRecord() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+	if _, err := patchRecord([]byte(`["Oslo"]`), nil); err == nil {
+		t.Errorf("patchRecord() of an array expected an error")
+	}
+}
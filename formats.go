@@ -0,0 +1,99 @@
+package main
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+)
+
+// ErrCSVHeader is returned when the header row of a CSV file names a column
+// LocationData does not have, or names one twice.
+var ErrCSVHeader = errors.New("invalid CSV header")
+
+// parserFor the decoder of a city file, picked by its extension: .ndjson and
+// .jsonl hold one record per line, .csv a header row naming the JSON fields
+// and one record per row, anything else a JSON array.
+func parserFor(path string) func([]byte) ([]LocationData, error) {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".ndjson", ".jsonl":
+		return parseCitiesNDJSON
+	case ".csv":
+		return parseCitiesCSV
+	}
+	return parseCities
+}
+
+// parseCitiesNDJSON decodes one JSON object per line, blank lines are skipped
+func parseCitiesNDJSON(data []byte) ([]LocationData, error) {
+	var cities []LocationData
+	for i, line := range bytes.Split(data, []byte("\n")) {
+		line = bytes.TrimSpace(line)
+		if len(line) == 0 {
+			continue
+		}
+		var city LocationData
+		if err := json.Unmarshal(line, &city); err != nil {
+			return nil, fmt.Errorf("line %d: %w", i+1, err)
+		}
+		cities = append(cities, city)
+	}
+	return cities, nil
+}
+
+// csvColumns setters of the CSV columns, named like the JSON fields
+var csvColumns = map[string]func(*LocationData, string){
+	"latitude":      func(c *LocationData, v string) { c.Latitude = v },
+	"longitude":     func(c *LocationData, v string) { c.Longitude = v },
+	"geo":           func(c *LocationData, v string) { c.Geo = v },
+	"city":          func(c *LocationData, v string) { c.Name = v },
+	"province_icon": func(c *LocationData, v string) { c.ProvinceIcon = v },
+	"province":      func(c *LocationData, v string) { c.Province = v },
+	"country_icon":  func(c *LocationData, v string) { c.CountryIcon = v },
+	"country":       func(c *LocationData, v string) { c.Country = v },
+	"hemisphere":    func(c *LocationData, v string) { c.Hemisphere = v },
+}
+
+// parseCitiesCSV decodes a header row and one record per row, columns missing
+// from the header are left empty. An empty file holds no records.
+func parseCitiesCSV(data []byte) ([]LocationData, error) {
+	r := csv.NewReader(bytes.NewReader(data))
+	header, err := r.Read()
+	if err == io.EOF {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	setters := make([]func(*LocationData, string), len(header))
+	seen := make(map[string]bool, len(header))
+	for i, name := range header {
+		name = strings.ToLower(strings.TrimSpace(name))
+		set, ok := csvColumns[name]
+		if !ok || seen[name] {
+			return nil, fmt.Errorf("%w: column %d %q", ErrCSVHeader, i+1, header[i])
+		}
+		setters[i], seen[name] = set, true
+	}
+
+	var cities []LocationData
+	for {
+		row, err := r.Read()
+		if err == io.EOF {
+			return cities, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		var city LocationData
+		for i, value := range row {
+			setters[i](&city, value)
+		}
+		cities = append(cities, city)
+	}
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestParserFor(t *testing.T) {
+	morocco := LocationData{Latitude: "34.02", Longitude: "6.50", Geo: "34.02, 6.50", Name: "Rabat", Country: "Morocco"}
+	tests := []struct {
+		name       string
+		file       string
+		content    string
+		want       []LocationData
+		wantReason string // classifyLoadError of the error, empty when it loads
+	}{
+		{
+			name:    "json",
+			file:    "cities.json",
+			content: `[{"latitude": "34.02", "longitude": "6.50", "geo": "34.02, 6.50", "city": "Rabat", "country": "Morocco"}]`,
+			want:    []LocationData{morocco},
+		},
+		{
+			name:    "ndjson",
+			file:    "cities.ndjson",
+			content: "{\"city\": \"Rabat\", \"country\": \"Morocco\", \"geo\": \"34.02, 6.50\", \"latitude\": \"34.02\", \"longitude\": \"6.50\"}\n\n{\"city\": \"Oslo\"}\n",
+			want:    []LocationData{morocco, {Name: "Oslo"}},
+		},
+		{
+			name:    "jsonl",
+			file:    "CITIES.JSONL",
+			content: `{"city": "Oslo"}`,
+			want:    []LocationData{{Name: "Oslo"}},
+		},
+		{
+			name:    "csv",
+			file:    "cities.csv",
+			content: "City,country,geo,latitude,longitude\nRabat,Morocco,\"34.02, 6.50\",34.02,6.50\n",
+			want:    []LocationData{morocco},
+		},
+		{
+			name:    "empty csv",
+			file:    "cities.csv",
+			content: "",
+		},
+		{
+			name:       "ndjson syntax",
+			file:       "cities.ndjson",
+			content:    "{\"city\": \"Oslo\"}\n{\"city\": \n",
+			wantReason: "syntax",
+		},
+		{
+			name:       "ndjson array line",
+			file:       "cities.ndjson",
+			content:    `[{"city": "Oslo"}]`,
+			wantReason: "type",
+		},
+		{
+			name:       "csv field count",
+			file:       "cities.csv",
+			content:    "city,country\nOslo\n",
+			wantReason: "syntax",
+		},
+		{
+			name:       "csv unknown column",
+			file:       "cities.csv",
+			content:    "city,population\nOslo,700000\n",
+			wantReason: "schema",
+		},
+		{
+			name:       "csv duplicate column",
+			file:       "cities.csv",
+			content:    "city,City\nOslo,Oslo\n",
+			wantReason: "schema",
+		},
+		{
+			name:       "json type",
+			file:       "cities.json",
+			content:    `{"city": "Oslo"}`,
+			wantReason: "type",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parserFor(tt.file)([]byte(tt.content))
+			if tt.wantReason != "" {
+				if err == nil {
+					t.Fatalf("parserFor(%s)() = %v, want a %s error", tt.file, got, tt.wantReason)
+				}
+				if reason := classifyLoadError(err); reason != tt.wantReason {
+					t.Errorf("classifyLoadError(%v) = %q, want %q", err, reason, tt.wantReason)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("parserFor(%s)() error = %v", tt.file, err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parserFor(%s)() = %+v, want %+v", tt.file, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseCitiesCSVHeaderError(t *testing.T) {
+	_, err := parseCitiesCSV([]byte("city,mayor\n"))
+	if !errors.Is(err, ErrCSVHeader) {
+		t.Errorf("parseCitiesCSV() error = %v, want ErrCSVHeader", err)
+	}
+}
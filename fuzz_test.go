@@ -0,0 +1,179 @@
+package main
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// fuzzSeeds raw contents and records of tmp/ and of a few cities.json
+// entries. The whole of cities.json would slow every fuzz execution down.
+func fuzzSeeds(f *testing.F) (files [][]byte, cities [][]LocationData) {
+	f.Helper()
+	paths, err := filepath.Glob(filepath.Join("tmp", "*.json"))
+	if err != nil {
+		f.Fatal(err)
+	}
+	for _, path := range paths {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			f.Fatal(err)
+		}
+		files = append(files, data)
+		if loaded, err := parseCities(data); err == nil {
+			cities = append(cities, loaded)
+		}
+	}
+
+	reference, err := loadDataToStruct("cities.json")
+	if err != nil {
+		f.Fatal(err)
+	}
+	slice := reference[:min(len(reference), fuzzReferenceSeedSize)]
+	data, err := json.Marshal(slice)
+	if err != nil {
+		f.Fatal(err)
+	}
+	return append(files, data), append(cities, slice)
+}
+
+// fuzzReferenceSeedSize cities.json entries fuzzSeeds adds as a seed
+const fuzzReferenceSeedSize = 5
+
+// loadFuzzReference loads cities.json once for a fuzz target
+func loadFuzzReference(f *testing.F) {
+	f.Helper()
+	if _, err := loadAuthenticCities("cities.json", loadDataToStruct, GetUniqueKey, FirstWins); err != nil {
+		f.Fatal(err)
+	}
+}
+
+// checkLoaded what every loader has to guarantee: errors are classified, and
+// every record it returns ends up exactly once in valid or invalid
+func checkLoaded(t *testing.T, cities []LocationData, err error) {
+	t.Helper()
+	if err != nil {
+		if reason := classifyLoadError(err); reason == "other" {
+			t.Fatalf("unclassified load error %#v: %v", err, err)
+		}
+		if cities != nil {
+			t.Fatalf("loader returned %d records with error %v", len(cities), err)
+		}
+		return
+	}
+
+	result := loadedReference().validateCities("fuzz", cities, HelperUtils{readData, parseCityFile, GetUniqueKey, hardCheck, getAllFiles})
+
+	if len(result.Records) != len(cities) {
+		t.Fatalf("validateCities() returned %d records for %d cities", len(result.Records), len(cities))
+	}
+	for i, record := range result.Records {
+		if record.Index != i {
+			t.Fatalf("record %d has index %d", i, record.Index)
+		}
+	}
+	valid, invalid, unprocessable := splitResults([]FileResult{result})
+	if len(valid)+len(invalid) != len(cities) || len(unprocessable) != 0 {
+		t.Fatalf("%d cities split into %d valid and %d invalid records", len(cities), len(valid), len(invalid))
+	}
+}
+
+func FuzzParseCities(f *testing.F) {
+	loadFuzzReference(f)
+	files, _ := fuzzSeeds(f)
+	for _, data := range files {
+		f.Add(data)
+	}
+	f.Add([]byte(`null`))
+	f.Add([]byte(`[{"city": 1}]`))
+	f.Add([]byte(`[{"latitude": "25.40"`))
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		cities, err := parseCities(data)
+		checkLoaded(t, cities, err)
+	})
+}
+
+func FuzzParseCitiesNDJSON(f *testing.F) {
+	loadFuzzReference(f)
+	_, cities := fuzzSeeds(f)
+	for _, loaded := range cities {
+		var buf bytes.Buffer
+		enc := json.NewEncoder(&buf)
+		for _, city := range loaded {
+			if err := enc.Encode(city); err != nil {
+				f.Fatal(err)
+			}
+		}
+		f.Add(buf.Bytes())
+	}
+	f.Add([]byte("{}\n\n  \n{\"city\": \"Oslo\"}"))
+	f.Add([]byte("{\"city\": \"Oslo\"} {}\n"))
+	f.Add([]byte("[]\n"))
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		cities, err := parseCitiesNDJSON(data)
+		checkLoaded(t, cities, err)
+	})
+}
+
+func FuzzParseCitiesCSV(f *testing.F) {
+	loadFuzzReference(f)
+	_, cities := fuzzSeeds(f)
+	for _, loaded := range cities {
+		var buf bytes.Buffer
+		w := csv.NewWriter(&buf)
+		_ = w.Write([]string{"latitude", "longitude", "geo", "city", "province_icon", "province", "country_icon", "country", "hemisphere"})
+		for _, c := range loaded {
+			_ = w.Write([]string{c.Latitude, c.Longitude, c.Geo, c.Name, c.ProvinceIcon, c.Province, c.CountryIcon, c.Country, c.Hemisphere})
+		}
+		w.Flush()
+		f.Add(buf.Bytes())
+	}
+	f.Add([]byte("city,country\n\"Oslo\",Norway,extra\n"))
+	f.Add([]byte("city,city\n"))
+	f.Add([]byte("\"city\nOslo"))
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		cities, err := parseCitiesCSV(data)
+		checkLoaded(t, cities, err)
+	})
+}
+
+func FuzzResolveGeoPoint(f *testing.F) {
+	_, cities := fuzzSeeds(f)
+	for _, loaded := range cities {
+		for _, c := range loaded {
+			f.Add(c.Latitude, c.Longitude, c.Hemisphere, c.Country)
+		}
+	}
+	f.Add("-25.40S", "100.18", "", "Mexico")
+	f.Add("N 0", "W180", "SW", "Fiji")
+	f.Add("NaN", "Inf", "", "Norway")
+	f.Add("0x1p-2", "1e2", "n,e", "")
+
+	f.Fuzz(func(t *testing.T, latitude, longitude, hemisphere, country string) {
+		for _, axis := range []struct {
+			value              string
+			positive, negative byte
+		}{{latitude, 'N', 'S'}, {longitude, 'E', 'W'}} {
+			deg, _, err := parseCoordinate(axis.value, axis.positive, axis.negative)
+			if err == nil && (math.IsNaN(deg) || math.IsInf(deg, 0)) {
+				t.Fatalf("parseCoordinate(%q) = %v, want a finite number", axis.value, deg)
+			}
+		}
+
+		p, err := ResolveGeoPoint(LocationData{Latitude: latitude, Longitude: longitude, Hemisphere: hemisphere, Country: country})
+		if err != nil && !errors.Is(err, ErrHemisphereAmbiguous) {
+			return
+		}
+		if math.IsNaN(p.Lat) || math.IsNaN(p.Lon) || p.Lat < -90 || p.Lat > 90 || p.Lon < -180 || p.Lon > 180 {
+			t.Fatalf("ResolveGeoPoint(%q, %q, %q, %q) = %+v, out of range", latitude, longitude, hemisphere, country, p)
+		}
+	})
+}
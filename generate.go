@@ -0,0 +1,148 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"log/slog"
+	"math/rand"
+	"os"
+	"path/filepath"
+)
+
+// DatasetOptions shape of a synthetic dataset
+type DatasetOptions struct {
+	Files          int
+	RecordsPerFile int
+	InvalidRatio   float64 // share of the records altered so they fail validation
+	CorruptRatio   float64 // share of the files truncated so they cannot be parsed
+	Seed           int64
+}
+
+// DatasetSummary what GenerateDataset wrote, the outcome a run over it must report
+type DatasetSummary struct {
+	Files   int `json:"files"`
+	Valid   int `json:"valid"`
+	Invalid int `json:"invalid"`
+	Corrupt int `json:"corrupt"` // files, their records are in neither Valid nor Invalid
+}
+
+// GenerateDataset writes opts.Files files of opts.RecordsPerFile records to
+// dir, sampled from reference. Keys that occur more than once in reference are
+// left out, so every unaltered record is valid whatever the duplicate policy.
+// The same options and reference always give the same files.
+func GenerateDataset(dir string, reference []LocationData, opts DatasetOptions) (DatasetSummary, error) {
+	var summary DatasetSummary
+	if opts.Files < 0 || opts.RecordsPerFile < 0 {
+		return summary, errors.New("file and record counts must not be negative")
+	}
+	if opts.InvalidRatio < 0 || opts.InvalidRatio > 1 || opts.CorruptRatio < 0 || opts.CorruptRatio > 1 {
+		return summary, errors.New("ratios must be between 0 and 1")
+	}
+
+	counts := make(map[Key]int, len(reference))
+	for _, city := range reference {
+		counts[GetUniqueKey(city)]++
+	}
+	var pool []LocationData
+	for _, city := range reference {
+		if counts[GetUniqueKey(city)] == 1 {
+			pool = append(pool, city)
+		}
+	}
+	if len(pool) == 0 && opts.RecordsPerFile > 0 {
+		return summary, errors.New("no reference entries to sample from")
+	}
+
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return summary, err
+	}
+
+	rng := rand.New(rand.NewSource(opts.Seed))
+	for i := 0; i < opts.Files; i++ {
+		records := make([]LocationData, opts.RecordsPerFile)
+		invalid := 0
+		for j := range records {
+			records[j] = pool[rng.Intn(len(pool))]
+			if rng.Float64() < opts.InvalidRatio {
+				records[j] = alterRecord(records[j], rng)
+				invalid++
+			}
+		}
+
+		data, err := json.MarshalIndent(records, "", "    ")
+		if err != nil {
+			return summary, err
+		}
+		if rng.Float64() < opts.CorruptRatio {
+			data = data[:len(data)/2]
+			summary.Corrupt++
+		} else {
+			summary.Valid += len(records) - invalid
+			summary.Invalid += invalid
+		}
+
+		path := filepath.Join(dir, fmt.Sprintf("city-%d.json", i+1))
+		if err := os.WriteFile(path, data, 0o644); err != nil {
+			return summary, err
+		}
+		summary.Files++
+	}
+	return summary, nil
+}
+
+// alterRecord changes record so it no longer validates, either by a field the
+// key does not cover or by a name no reference entry has
+func alterRecord(record LocationData, rng *rand.Rand) LocationData {
+	switch rng.Intn(3) {
+	case 0:
+		record.Province += " (synthetic)"
+	case 1:
+		record.Latitude, record.Longitude = record.Longitude, record.Latitude+"1"
+	default:
+		record.Name += " Synthetic"
+	}
+	return record
+}
+
+// runGenerate handles `generate [flags] dir`
+func runGenerate(args []string) int {
+	fs := flag.NewFlagSet("generate", flag.ExitOnError)
+	applyLogging := addLogFlags(fs)
+	reference := fs.String("reference", "cities.json", "reference data the records are sampled from")
+	files := fs.Int("files", 100, "number of files")
+	records := fs.Int("records", 10, "records per file")
+	invalid := fs.Float64("invalid", 0.2, "share of the records that fail validation")
+	corrupt := fs.Float64("corrupt", 0.01, "share of the files that cannot be parsed")
+	seed := fs.Int64("seed", 1, "random seed, the same seed gives the same files")
+	_ = fs.Parse(args)
+
+	if err := applyLogging(); err != nil {
+		slog.Error("parsing flags", "err", err)
+		return 2
+	}
+	if fs.NArg() != 1 {
+		slog.Error("parsing flags", "err", "usage: generate [flags] dir")
+		return 2
+	}
+
+	cities, err := loadDataToStruct(*reference)
+	if err != nil {
+		slog.Error("loading reference data", "reference", *reference, "err", err)
+		return 1
+	}
+
+	summary, err := GenerateDataset(fs.Arg(0), cities, DatasetOptions{
+		Files: *files, RecordsPerFile: *records, InvalidRatio: *invalid, CorruptRatio: *corrupt, Seed: *seed,
+	})
+	if err != nil {
+		slog.Error("generating dataset", "dir", fs.Arg(0), "err", err)
+		return 1
+	}
+	if err := json.NewEncoder(os.Stdout).Encode(summary); err != nil {
+		slog.Error("writing summary", "err", err)
+		return 1
+	}
+	return 0
+}
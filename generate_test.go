@@ -0,0 +1,79 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestGenerateDataset(t *testing.T) {
+	reference, err := loadDataToStruct("cities.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := loadAuthenticCities("cities.json", loadDataToStruct, GetUniqueKey, LastWins); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		opts    DatasetOptions
+		wantErr bool
+	}{
+		{name: "all valid", opts: DatasetOptions{Files: 5, RecordsPerFile: 20, Seed: 1}},
+		{name: "mixed", opts: DatasetOptions{Files: 40, RecordsPerFile: 25, InvalidRatio: 0.3, CorruptRatio: 0.2, Seed: 2}},
+		{name: "all corrupt", opts: DatasetOptions{Files: 3, RecordsPerFile: 4, CorruptRatio: 1, Seed: 3}},
+		{name: "empty files", opts: DatasetOptions{Files: 2, Seed: 4}},
+		{name: "bad ratio", opts: DatasetOptions{Files: 1, RecordsPerFile: 1, InvalidRatio: 1.5}, wantErr: true},
+		{name: "negative count", opts: DatasetOptions{Files: -1}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			summary, err := GenerateDataset(dir, reference, tt.opts)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("GenerateDataset() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+
+			valid, invalid, unprocessable := ProcessFilesOrdered(dir, HelperUtils{readData, parseCityFile, GetUniqueKey, hardCheck, getAllFiles})
+			got := DatasetSummary{Files: tt.opts.Files, Valid: len(valid), Invalid: len(invalid), Corrupt: len(unprocessable)}
+			if got != summary {
+				t.Errorf("run over the dataset = %+v, GenerateDataset() = %+v", got, summary)
+			}
+			if summary.Valid+summary.Invalid+summary.Corrupt*tt.opts.RecordsPerFile != tt.opts.Files*tt.opts.RecordsPerFile {
+				t.Errorf("summary %+v does not add up to %d records", summary, tt.opts.Files*tt.opts.RecordsPerFile)
+			}
+		})
+	}
+}
+
+func TestGenerateDatasetDeterministic(t *testing.T) {
+	reference, err := loadDataToStruct("cities.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	opts := DatasetOptions{Files: 3, RecordsPerFile: 5, InvalidRatio: 0.5, CorruptRatio: 0.3, Seed: 7}
+	first, second := t.TempDir(), t.TempDir()
+	for _, dir := range []string{first, second} {
+		if _, err := GenerateDataset(dir, reference, opts); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for i := 1; i <= opts.Files; i++ {
+		name := fmt.Sprintf("city-%d.json", i)
+		a, errA := os.ReadFile(filepath.Join(first, name))
+		b, errB := os.ReadFile(filepath.Join(second, name))
+		if errA != nil || errB != nil {
+			t.Fatalf("reading %s: %v, %v", name, errA, errB)
+		}
+		if !bytes.Equal(a, b) {
+			t.Errorf("%s differs between two runs with seed %d", name, opts.Seed)
+		}
+	}
+}
@@ -1,3 +1,7 @@
 module validate_cites_challenge
 
 go 1.22.1
+
+require golang.org/x/text v0.21.0
+
+require golang.org/x/sync v0.10.0
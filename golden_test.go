@@ -0,0 +1,153 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+var update = flag.Bool("update", false, "rewrite the golden files in testdata/golden")
+
+// goldenDir fixtures and the expected reports, <case>.<format>.golden
+const goldenDir = "testdata/golden"
+
+// TestValidateGolden runs the validate command over the fixtures and compares
+// what it prints in every format
+func TestValidateGolden(t *testing.T) {
+	keepDefaultLogger(t)
+	tests := []struct {
+		name     string
+		fixtures bool     // copy the fixtures of goldenDir/mixed to tmp/
+		before   []string // flags of a run made first, e.g. to fill the cache
+		args     []string
+	}{
+		{name: "mixed", fixtures: true},
+		{name: "empty"},
+		{
+			name:     "cache and quarantine",
+			fixtures: true,
+			before:   []string{"-cache", ".validate-cache.json"},
+			args:     []string{"-cache", ".validate-cache.json", "-cache-stats", "-quarantine"},
+		},
+		{name: "sharded engine", fixtures: true, args: []string{"-engine", "sharded"}}, // records without a location
+	}
+	for _, tt := range tests {
+		for _, format := range []ReportFormat{TextReport, JSONReport, CSVReport} {
+			name := strings.ReplaceAll(tt.name, " ", "_") + "." + string(format)
+			t.Run(name, func(t *testing.T) {
+				got := runGolden(t, tt.fixtures, tt.before, append(tt.args, "-format", string(format)))
+				compareGolden(t, filepath.Join(goldenDir, name+".golden"), got)
+			})
+		}
+	}
+}
+
+// runGolden runs the validate command with args in a new folder holding
+// cities.json and tmp/, as a user would, and returns what it printed.
+// With before, a run with those flags comes first.
+func runGolden(t *testing.T, fixtures bool, before, args []string) []byte {
+	t.Helper()
+	dir := t.TempDir()
+	copyGoldenFile(t, filepath.Join(goldenDir, "reference.json"), filepath.Join(dir, "cities.json"))
+	if err := os.Mkdir(filepath.Join(dir, "tmp"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if fixtures {
+		entries, err := os.ReadDir(filepath.Join(goldenDir, "mixed"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, entry := range entries {
+			copyGoldenFile(t, filepath.Join(goldenDir, "mixed", entry.Name()), filepath.Join(dir, "tmp", entry.Name()))
+		}
+	}
+
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(dir); err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		if err := os.Chdir(wd); err != nil {
+			t.Fatal(err)
+		}
+	}()
+
+	common := []string{"-quiet", "-log-level", "error"}
+	if before != nil {
+		captureStdout(t, append(common, before...))
+	}
+	return captureStdout(t, append(common, args...))
+}
+
+// captureStdout runs the validate command with args and returns its stdout
+func captureStdout(t *testing.T, args []string) []byte {
+	t.Helper()
+	out, err := os.CreateTemp(t.TempDir(), "stdout")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer out.Close()
+
+	stdout := os.Stdout
+	os.Stdout = out
+	code := runValidate(args)
+	os.Stdout = stdout
+	if code != 0 {
+		t.Fatalf("runValidate(%v) = %d, want 0", args, code)
+	}
+
+	data, err := os.ReadFile(out.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+func copyGoldenFile(t *testing.T, src, dst string) {
+	t.Helper()
+	data, err := os.ReadFile(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(dst, data, 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// compareGolden compares got with the golden file at path, or rewrites it with -update
+func compareGolden(t *testing.T, path string, got []byte) {
+	t.Helper()
+	if *update {
+		if err := os.WriteFile(path, got, 0o644); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+
+	want, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("%v, run go test -run Golden -update to create it", err)
+	}
+	if bytes.Equal(got, want) {
+		return
+	}
+	gotLines, wantLines := strings.Split(string(got), "\n"), strings.Split(string(want), "\n")
+	for i := 0; i < len(gotLines) || i < len(wantLines); i++ {
+		var g, w string
+		if i < len(gotLines) {
+			g = gotLines[i]
+		}
+		if i < len(wantLines) {
+			w = wantLines[i]
+		}
+		if g != w {
+			t.Fatalf("%s differs at line %d:\n got: %s\nwant: %s\nrun go test -run Golden -update if the change is intended", path, i+1, g, w)
+		}
+	}
+}
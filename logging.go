@@ -0,0 +1,43 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+)
+
+// addLogFlags registers the logging flags on fs, the returned func installs
+// the configured logger as the slog default once fs has been parsed.
+// Diagnostics always go to stderr, stdout is kept for results.
+func addLogFlags(fs *flag.FlagSet) func() error {
+	level := fs.String("log-level", "info", "minimum level of the diagnostics on stderr: debug, info, warn or error")
+	format := fs.String("log-format", "text", "format of the diagnostics on stderr: text or json")
+
+	return func() error {
+		logger, err := newLogger(os.Stderr, *level, *format)
+		if err != nil {
+			return err
+		}
+		slog.SetDefault(logger)
+		return nil
+	}
+}
+
+// newLogger logger writing to w with a text or json handler
+func newLogger(w io.Writer, level, format string) (*slog.Logger, error) {
+	var l slog.Level
+	if err := l.UnmarshalText([]byte(level)); err != nil {
+		return nil, err
+	}
+	opts := &slog.HandlerOptions{Level: l}
+
+	switch format {
+	case "text":
+		return slog.New(slog.NewTextHandler(w, opts)), nil
+	case "json":
+		return slog.New(slog.NewJSONHandler(w, opts)), nil
+	}
+	return nil, fmt.Errorf("unknown log format %q", format)
+}
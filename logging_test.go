@@ -0,0 +1,63 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"log"
+	"log/slog"
+	"strings"
+	"testing"
+)
+
+func TestNewLogger(t *testing.T) {
+	tests := []struct {
+		name    string
+		level   string
+		format  string
+		want    string
+		wantErr bool
+	}{
+		{name: "text", level: "info", format: "text", want: "level=WARN msg=\"unprocessable file\" file=tmp/city-21.json"},
+		{name: "json", level: "debug", format: "json", want: `"file":"tmp/city-21.json"`},
+		{name: "filtered", level: "error", format: "text", want: ""},
+		{name: "unknown format", level: "info", format: "xml", wantErr: true},
+		{name: "unknown level", level: "loud", format: "text", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var out bytes.Buffer
+			logger, err := newLogger(&out, tt.level, tt.format)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("newLogger() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+
+			logger.With("file", "tmp/city-21.json").Warn("unprocessable file")
+			if tt.want == "" {
+				if out.Len() != 0 {
+					t.Errorf("logged %q, want nothing", out.String())
+				}
+				return
+			}
+			if !strings.Contains(out.String(), tt.want) {
+				t.Errorf("logged %q, want it to contain %q", out.String(), tt.want)
+			}
+			if tt.format == "json" && !json.Valid(out.Bytes()) {
+				t.Errorf("logged %q, want valid JSON", out.String())
+			}
+		})
+	}
+}
+
+// keepDefaultLogger restores the default loggers the flags of a command
+// replace. slog.SetDefault also redirects the log package, so it is restored too.
+func keepDefaultLogger(t *testing.T) {
+	logger, out, flags := slog.Default(), log.Writer(), log.Flags()
+	t.Cleanup(func() {
+		slog.SetDefault(logger)
+		log.SetOutput(out)
+		log.SetFlags(flags)
+	})
+}
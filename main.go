@@ -1,23 +1,289 @@
 package main
 
 import (
-	"fmt"
+	"context"
+	"encoding/json"
+	"flag"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
 )
 
 func main() {
-	err := loadAuthenticCities("cities.json", loadDataToStruct, GetUniqueKey)
+	args := os.Args[1:]
+	if len(args) > 0 {
+		switch args[0] {
+		case "reference":
+			os.Exit(runReferenceCommand(args[1:]))
+		case "serve":
+			os.Exit(runServe(args[1:]))
+		case "fix":
+			os.Exit(runFix(args[1:]))
+		case "watch":
+			os.Exit(runWatch(args[1:]))
+		case "generate":
+			os.Exit(runGenerate(args[1:]))
+		}
+	}
+	os.Exit(runValidate(args))
+}
+
+// addNormalizationFlags registers the name matching flags on fs, the returned
+// func applies them to nameNormalization once fs has been parsed.
+func addNormalizationFlags(fs *flag.FlagSet) func() error {
+	normalize := fs.String("normalize", string(DefaultNormalization.Form), "unicode normalization applied to names: none, nfc or nfkc")
+	caseFold := fs.Bool("casefold", false, "compare names case-insensitively")
+	stripDiacritics := fs.Bool("strip-diacritics", false, "ignore accents when comparing names")
+	keepWhitespace := fs.Bool("keep-whitespace", false, "do not trim and collapse whitespace in names")
+
+	return func() error {
+		form, err := ParseNormalizationForm(*normalize)
+		if err != nil {
+			return err
+		}
+		nameNormalization = NormalizeOptions{
+			Form:            form,
+			CaseFold:        *caseFold,
+			TrimSpace:       !*keepWhitespace,
+			StripDiacritics: *stripDiacritics,
+		}
+		return nil
+	}
+}
+
+// stringList flag.Value collecting every occurrence of a repeatable flag
+type stringList []string
+
+func (l *stringList) String() string {
+	return strings.Join(*l, ",")
+}
+
+func (l *stringList) Set(v string) error {
+	*l = append(*l, v)
+	return nil
+}
+
+// referenceFlags reference layers and duplicate policy of the commands that load reference data
+type referenceFlags struct {
+	paths      stringList
+	duplicates *string
+}
+
+func addReferenceFlags(fs *flag.FlagSet) *referenceFlags {
+	f := &referenceFlags{}
+	fs.Var(&f.paths, "reference", "reference data file, repeat to add override layers (default cities.json)")
+	f.duplicates = fs.String("duplicates", string(FirstWins), "duplicate reference keys: first, last, fail or merge")
+	return f
+}
+
+// sources reference layers in the order they were given, later ones win
+func (f *referenceFlags) sources() []string {
+	if len(f.paths) == 0 {
+		return []string{"cities.json"}
+	}
+	return f.paths
+}
+
+func (f *referenceFlags) policy() (DuplicatePolicy, error) {
+	return ParseDuplicatePolicy(*f.duplicates)
+}
+
+func runValidate(args []string) int {
+	fs := flag.NewFlagSet("validate", flag.ExitOnError)
+	applyNormalization := addNormalizationFlags(fs)
+	applyLogging := addLogFlags(fs)
+	reference := addReferenceFlags(fs)
+	quarantine := fs.Bool("quarantine", false, "move files with invalid records or that cannot be parsed out of tmp")
+	invalidDir := fs.String("quarantine-invalid", filepath.Join("quarantine", "invalid"), "folder for files with invalid records")
+	unparseableDir := fs.String("quarantine-unparseable", filepath.Join("quarantine", "unparseable"), "folder for files that cannot be parsed")
+	acceptedDir := fs.String("accepted", "", "folder for files whose records are all valid, empty leaves them in tmp")
+	copyFiles := fs.Bool("copy", false, "copy files instead of moving them")
+	cachePath := fs.String("cache", "", "result cache index, files unchanged since the last run are not validated again")
+	cacheClear := fs.Bool("cache-clear", false, "discard the cached results before the run")
+	cacheStats := fs.Bool("cache-stats", false, "print the cache hit rate")
+	checkpointPath := fs.String("checkpoint", "", "write the progress of the run to this file so it can be resumed")
+	checkpointInterval := fs.Duration("checkpoint-interval", 5*time.Second, "how often the checkpoint is written")
+	resume := fs.Bool("resume", false, "continue the run recorded in -checkpoint")
+	ordered := fs.Bool("ordered", true, "report records ordered by file path and record index instead of as files finish")
+	workers := fs.Int("workers", 0, "files validated at the same time, 0 uses GOMAXPROCS")
+	eventsPath := fs.String("events", "", "write every result as a JSON line to this file while the run progresses")
+	quiet := fs.Bool("quiet", false, "do not report progress")
+	progressInterval := fs.Duration("progress-interval", 2*time.Second, "how often progress is reported to stderr")
+	engineName := fs.String("engine", "stream", "processing engine: "+strings.Join(EngineNames(), ", "))
+	profiles := addProfileFlags(fs)
+	formatName := fs.String("format", string(TextReport), "report format on stdout: text, json or csv (records only)")
+	_ = fs.Parse(args)
+
+	if err := applyLogging(); err != nil {
+		slog.Error("parsing flags", "err", err)
+		return 2
+	}
+	if err := applyNormalization(); err != nil {
+		slog.Error("parsing flags", "err", err)
+		return 2
+	}
+	policy, err := reference.policy()
+	if err != nil {
+		slog.Error("parsing flags", "err", err)
+		return 2
+	}
+	format, err := ParseReportFormat(*formatName)
+	if err != nil {
+		slog.Error("parsing flags", "err", err)
+		return 2
+	}
+	if *progressInterval <= 0 {
+		slog.Error("parsing flags", "err", "-progress-interval must be positive, use -quiet to turn progress off")
+		return 2
+	}
+	if *resume && *checkpointPath == "" {
+		slog.Error("parsing flags", "err", "-resume needs -checkpoint")
+		return 2
+	}
+	engine, err := NewEngine(*engineName, *workers, *ordered)
+	if err != nil {
+		slog.Error("parsing flags", "err", err)
+		return 2
+	}
+	// the other engines only report records, not the per file results these
+	// need, and do not report progress, so it is off unless asked for
+	useEngine := *engineName != "stream"
+	progressSet := false
+	fs.Visit(func(f *flag.Flag) { progressSet = progressSet || f.Name == "progress-interval" })
+	if useEngine && (*cachePath != "" || *checkpointPath != "" || *eventsPath != "" || *quarantine || *acceptedDir != "" || progressSet) {
+		slog.Error("parsing flags", "err", "-cache, -checkpoint, -events, -progress-interval, -quarantine and -accepted need -engine stream")
+		return 2
+	}
+
+	conflicts, err := loadReferenceLayers(reference.sources(), loadDataToStruct, GetUniqueKey, policy)
+	for _, conflict := range conflicts {
+		slog.Warn("duplicate reference entry", "source", conflict.Source, "index", conflict.Index,
+			"city", conflict.Duplicate.Name, "country", conflict.Duplicate.Country, "geo", conflict.Duplicate.Geo, "policy", conflict.Policy)
+	}
 	if err != nil {
-		fmt.Println("Error loading authentic cities:", err)
-		return
+		slog.Error("loading authentic cities", "err", err)
+		return 1
 	}
 
-	validated, inValid, unprocessable := ProcessFilesWithoutMutex("tmp",
-		HelperUtils{loadDataToStruct, GetUniqueKey, hardCheck, getAllFiles})
+	var cache *ResultCache
+	if *cachePath != "" {
+		if cache, err = OpenResultCache(*cachePath); err != nil {
+			slog.Error("reading result cache", "cache", *cachePath, "err", err)
+			return 1
+		}
+		if *cacheClear {
+			cache.Clear()
+		}
+	}
 
-	fmt.Println("Successfully Validated Elements:", validated)
-	fmt.Println("Unsuccessfully Validated Elements:", inValid)
-	fmt.Println("Unprocessable Files:", unprocessable)
-	fmt.Println("Successfully Validated Elements:", len(validated))
-	fmt.Println("Unsuccessfully Validated Elements:", len(inValid))
-	fmt.Println("Unprocessable Files:", len(unprocessable))
+	var checkpoint *Checkpointer
+	if *checkpointPath != "" {
+		if checkpoint, err = NewCheckpointer(*checkpointPath, *checkpointInterval, *resume); err != nil {
+			slog.Error("reading checkpoint", "checkpoint", *checkpointPath, "err", err)
+			return 1
+		}
+	}
+
+	var events ResultHandler
+	if *eventsPath != "" {
+		f, err := os.Create(*eventsPath)
+		if err != nil {
+			slog.Error("creating events file", "events", *eventsPath, "err", err)
+			return 1
+		}
+		defer f.Close()
+		enc := json.NewEncoder(f)
+		events = func(event ResultEvent) error { return enc.Encode(event) }
+	}
+
+	var progress *ProgressReporter
+	if !*quiet && !useEngine {
+		progress = NewProgressReporter(os.Stderr, *progressInterval)
+	}
+
+	stopProfiles, err := profiles.start()
+	if err != nil {
+		slog.Error("starting profiles", "err", err)
+		return 1
+	}
+
+	helpers := HelperUtils{readData, parseCityFile, GetUniqueKey, hardCheck, getAllFiles}
+	// only the records the report lists are kept, and for quarantining the
+	// invalid records of each file, not every FileResult of the run
+	keepFiles := *quarantine || *acceptedDir != ""
+	var quarantined []FileResult
+	var report Report
+	if useEngine {
+		var result EngineResult
+		result, err = engine.Process(context.Background(), "tmp", helpers)
+		report = NewReport(result.Valid, result.Invalid, result.Unprocessable)
+	} else {
+		err = forEachFileResult(context.Background(), "tmp", helpers,
+			RunOptions{Ordered: *ordered, Workers: *workers, Cache: cache, Checkpoint: checkpoint, Progress: progress},
+			func(result FileResult) error {
+				report.addFile(result)
+				if keepFiles {
+					result.Records = result.Invalid()
+					quarantined = append(quarantined, result)
+				}
+				if events == nil {
+					return nil
+				}
+				return emitFileEvents(result, events)
+			})
+	}
+	if err := stopProfiles(); err != nil {
+		slog.Error("writing profiles", "err", err)
+		return 1
+	}
+	if err != nil {
+		slog.Error("processing tmp folder", "dir", "tmp", "err", err)
+		return 1
+	}
+	if checkpoint != nil {
+		if checkpoint.Stale() {
+			slog.Warn("checkpoint was written for other files or reference data, started over", "checkpoint", *checkpointPath)
+		}
+		if *resume {
+			slog.Info("resumed from checkpoint", "checkpoint", *checkpointPath, "files", checkpoint.Resumed())
+		}
+		if err := checkpoint.Finish(); err != nil {
+			slog.Error("removing checkpoint", "err", err)
+			return 1
+		}
+	}
+	if cache != nil {
+		if err := cache.Save(); err != nil {
+			slog.Error("writing result cache", "err", err)
+			return 1
+		}
+	}
+	if !useEngine {
+		report = loadedReference().complete(report)
+	}
+	if cache != nil && *cacheStats {
+		stats := cache.Stats()
+		report.Cache = &stats
+	}
+
+	var quarantineErr error
+	if keepFiles {
+		opts := QuarantineOptions{AcceptedDir: *acceptedDir, Copy: *copyFiles}
+		if *quarantine {
+			opts.InvalidDir, opts.UnparseableDir = *invalidDir, *unparseableDir
+		}
+		report.Quarantine, quarantineErr = QuarantineFiles(quarantined, opts)
+	}
+
+	if err := WriteReport(os.Stdout, format, report); err != nil {
+		slog.Error("writing report", "err", err)
+		return 1
+	}
+	if quarantineErr != nil {
+		slog.Error("quarantining files", "err", quarantineErr)
+		return 1
+	}
+	return 0
 }
@@ -0,0 +1,328 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// metric one metric family in the Prometheus text exposition format
+type metric interface {
+	write(w io.Writer) error
+}
+
+// MetricsRegistry metric families in the order they were registered
+type MetricsRegistry struct {
+	mu      sync.Mutex
+	metrics []metric
+}
+
+func (r *MetricsRegistry) register(m metric) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.metrics = append(r.metrics, m)
+}
+
+// WriteText writes every metric in the Prometheus text format, version 0.0.4
+func (r *MetricsRegistry) WriteText(w io.Writer) error {
+	r.mu.Lock()
+	metrics := append([]metric(nil), r.metrics...)
+	r.mu.Unlock()
+
+	for _, m := range metrics {
+		if err := m.write(w); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ServeHTTP serves the metrics, for /metrics
+func (r *MetricsRegistry) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	_ = r.WriteText(w)
+}
+
+func writeHeader(w io.Writer, name, help, kind string) error {
+	_, err := fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, kind)
+	return err
+}
+
+// formatLabels renders names and values as {a="1",b="2"}, extra is appended as is
+func formatLabels(names, values []string, extra string) string {
+	if len(names) == 0 && extra == "" {
+		return ""
+	}
+	parts := make([]string, 0, len(names)+1)
+	for i, name := range names {
+		parts = append(parts, name+`="`+escapeLabel(values[i])+`"`)
+	}
+	if extra != "" {
+		parts = append(parts, extra)
+	}
+	return "{" + strings.Join(parts, ",") + "}"
+}
+
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func escapeLabel(v string) string {
+	return labelEscaper.Replace(v)
+}
+
+func formatFloat(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+// CounterVec counter with one series per combination of label values
+type CounterVec struct {
+	name, help string
+	labels     []string
+
+	mu     sync.Mutex
+	series map[string]*counterSeries
+}
+
+type counterSeries struct {
+	values []string
+	value  float64
+}
+
+// NewCounterVec counter registered with r
+func NewCounterVec(r *MetricsRegistry, name, help string, labels ...string) *CounterVec {
+	c := &CounterVec{name: name, help: help, labels: labels, series: make(map[string]*counterSeries)}
+	r.register(c)
+	return c
+}
+
+// Add adds delta to the series of values, there must be one value per label
+func (c *CounterVec) Add(delta float64, values ...string) {
+	if len(values) != len(c.labels) {
+		panic(fmt.Sprintf("%s: got %d label values, want %d", c.name, len(values), len(c.labels)))
+	}
+	key := strings.Join(values, "\xff")
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	s, ok := c.series[key]
+	if !ok {
+		s = &counterSeries{values: append([]string(nil), values...)}
+		c.series[key] = s
+	}
+	s.value += delta
+}
+
+// Inc adds 1 to the series of values
+func (c *CounterVec) Inc(values ...string) {
+	c.Add(1, values...)
+}
+
+// Value current value of the series of values, 0 when it was never touched
+func (c *CounterVec) Value(values ...string) float64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if s, ok := c.series[strings.Join(values, "\xff")]; ok {
+		return s.value
+	}
+	return 0
+}
+
+func (c *CounterVec) write(w io.Writer) error {
+	if err := writeHeader(w, c.name, c.help, "counter"); err != nil {
+		return err
+	}
+
+	c.mu.Lock()
+	keys := make([]string, 0, len(c.series))
+	for key := range c.series {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	lines := make([]string, 0, len(keys))
+	for _, key := range keys {
+		s := c.series[key]
+		lines = append(lines, c.name+formatLabels(c.labels, s.values, "")+" "+formatFloat(s.value))
+	}
+	c.mu.Unlock()
+
+	for _, line := range lines {
+		if _, err := fmt.Fprintln(w, line); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Histogram cumulative histogram over fixed upper bounds
+type Histogram struct {
+	name, help string
+	bounds     []float64
+
+	mu     sync.Mutex
+	counts []uint64 // per bucket, not cumulative
+	sum    float64
+	count  uint64
+}
+
+// defaultLatencyBuckets upper bounds in seconds for per file latencies
+var defaultLatencyBuckets = []float64{0.0001, 0.00025, 0.0005, 0.001, 0.0025, 0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1}
+
+// NewHistogram histogram registered with r, bounds must be increasing
+func NewHistogram(r *MetricsRegistry, name, help string, bounds []float64) *Histogram {
+	h := &Histogram{name: name, help: help, bounds: bounds, counts: make([]uint64, len(bounds)+1)}
+	r.register(h)
+	return h
+}
+
+// Observe records one value
+func (h *Histogram) Observe(v float64) {
+	i := sort.SearchFloat64s(h.bounds, v) // first bound >= v, len(bounds) for +Inf
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.counts[i]++
+	h.sum += v
+	h.count++
+}
+
+func (h *Histogram) write(w io.Writer) error {
+	if err := writeHeader(w, h.name, h.help, "histogram"); err != nil {
+		return err
+	}
+
+	h.mu.Lock()
+	counts := append([]uint64(nil), h.counts...)
+	sum, count := h.sum, h.count
+	h.mu.Unlock()
+
+	var cumulative uint64
+	for i, n := range counts {
+		cumulative += n
+		bound := math.Inf(1)
+		if i < len(h.bounds) {
+			bound = h.bounds[i]
+		}
+		if _, err := fmt.Fprintf(w, "%s_bucket{le=\"%s\"} %d\n", h.name, formatFloat(bound), cumulative); err != nil {
+			return err
+		}
+	}
+	_, err := fmt.Fprintf(w, "%s_sum %s\n%s_count %d\n", h.name, formatFloat(sum), h.name, count)
+	return err
+}
+
+// GaugeFunc gauge whose value is read when the metrics are written
+type GaugeFunc struct {
+	name, help string
+	value      func() float64
+}
+
+// NewGaugeFunc gauge registered with r
+func NewGaugeFunc(r *MetricsRegistry, name, help string, value func() float64) *GaugeFunc {
+	g := &GaugeFunc{name: name, help: help, value: value}
+	r.register(g)
+	return g
+}
+
+func (g *GaugeFunc) write(w io.Writer) error {
+	if err := writeHeader(w, g.name, g.help, "gauge"); err != nil {
+		return err
+	}
+	_, err := fmt.Fprintf(w, "%s %s\n", g.name, formatFloat(g.value()))
+	return err
+}
+
+// validationMetrics what the engines, watch mode and the service count
+type validationMetrics struct {
+	registry *MetricsRegistry
+
+	filesProcessed     *CounterVec
+	filesCached        *CounterVec
+	filesUnprocessable *CounterVec
+	recordsValid       *CounterVec
+	recordsInvalid     *CounterVec
+	loadSeconds        *Histogram
+	validateSeconds    *Histogram
+}
+
+// Failure reasons of invalid records
+const (
+	reasonUnknownKey    = "unknown_key"    // no reference entry with this city, country and geo
+	reasonFieldMismatch = "field_mismatch" // the reference entry has other field values
+)
+
+func newValidationMetrics() *validationMetrics {
+	r := &MetricsRegistry{}
+	m := &validationMetrics{
+		registry:           r,
+		filesProcessed:     NewCounterVec(r, "cities_files_processed_total", "Files validated, including unprocessable ones."),
+		filesCached:        NewCounterVec(r, "cities_files_cached_total", "Files whose result was taken from the cache or a resumed checkpoint.", "source"),
+		filesUnprocessable: NewCounterVec(r, "cities_files_unprocessable_total", "Files that could not be loaded.", "reason"),
+		recordsValid:       NewCounterVec(r, "cities_records_valid_total", "Records matching their reference entry.", "country"),
+		recordsInvalid:     NewCounterVec(r, "cities_records_invalid_total", "Records failing validation.", "country", "reason"),
+		loadSeconds:        NewHistogram(r, "cities_file_load_seconds", "Time to read and parse a file.", defaultLatencyBuckets),
+		validateSeconds:    NewHistogram(r, "cities_file_validate_seconds", "Time to validate the records of a loaded file.", defaultLatencyBuckets),
+	}
+	NewGaugeFunc(r, "cities_reference_entries", "Entries in the loaded reference registry.", func() float64 {
+		return float64(len(loadedReference().cities))
+	})
+	NewGaugeFunc(r, "cities_reference_version", "Number of reference loads so far.", func() float64 {
+		return float64(loadedReference().version)
+	})
+	return m
+}
+
+// metrics process wide validation metrics
+var metrics = newValidationMetrics()
+
+// observeRecord counts one validated record of country, see
+// referenceData.countryLabel. found tells whether its key was in the registry.
+func (m *validationMetrics) observeRecord(country string, found, valid bool) {
+	switch {
+	case valid:
+		m.recordsValid.Inc(country)
+	case found:
+		m.recordsInvalid.Inc(country, reasonFieldMismatch)
+	default:
+		m.recordsInvalid.Inc(country, reasonUnknownKey)
+	}
+}
+
+// observeUnprocessable counts a file that could not be loaded
+func (m *validationMetrics) observeUnprocessable(err error) {
+	m.filesUnprocessable.Inc(classifyLoadError(err))
+}
+
+// classifyLoadError short reason a file could not be loaded
+func classifyLoadError(err error) string {
+	var syntaxErr *json.SyntaxError
+	var csvErr *csv.ParseError
+	var typeErr *json.UnmarshalTypeError
+	var cachedErr *cachedLoadError
+	switch {
+	case errors.As(err, &cachedErr) && cachedErr.reason != "":
+		return cachedErr.reason
+	case errors.As(err, &syntaxErr), errors.As(err, &csvErr), errors.Is(err, io.ErrUnexpectedEOF):
+		return "syntax"
+	case errors.As(err, &typeErr):
+		return "type"
+	case errors.Is(err, ErrCSVHeader):
+		return "schema"
+	case errors.Is(err, fs.ErrNotExist), errors.Is(err, fs.ErrPermission):
+		return "read"
+	}
+	return "other"
+}
@@ -0,0 +1,149 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestMetricsRegistryWriteText(t *testing.T) {
+	r := &MetricsRegistry{}
+	counter := NewCounterVec(r, "test_records_total", "Records seen.", "country", "reason")
+	histogram := NewHistogram(r, "test_seconds", "Latency.", []float64{0.1, 1})
+	NewGaugeFunc(r, "test_entries", "Entries.", func() float64 { return 3 })
+
+	counter.Inc("Norway", "unknown_key")
+	counter.Add(2, `Côte "d'Ivoire"`, "field_mismatch")
+	counter.Inc("Norway", "unknown_key")
+	histogram.Observe(0.05)
+	histogram.Observe(0.1)
+	histogram.Observe(5)
+
+	var out bytes.Buffer
+	if err := r.WriteText(&out); err != nil {
+		t.Fatal(err)
+	}
+
+	want := `# HELP test_records_total Records seen.
+# TYPE test_records_total counter
+test_records_total{country="Côte \"d'Ivoire\"",reason="field_mismatch"} 2
+test_records_total{country="Norway",reason="unknown_key"} 2
+# HELP test_seconds Latency.
+# TYPE test_seconds histogram
+test_seconds_bucket{le="0.1"} 2
+test_seconds_bucket{le="1"} 2
+test_seconds_bucket{le="+Inf"} 3
+test_seconds_sum 5.15
+test_seconds_count 3
+# HELP test_entries Entries.
+# TYPE test_entries gauge
+test_entries 3
+`
+	if out.String() != want {
+		t.Errorf("WriteText() =\n%s\nwant\n%s", out.String(), want)
+	}
+}
+
+func TestValidateFileMetrics(t *testing.T) {
+	useReference(map[Key]LocationData{GetUniqueKey(oslo): oslo}, "")
+	wrongProvince := oslo
+	wrongProvince.Province = "Viken"
+
+	dir := t.TempDir()
+	writeReferenceFile(t, filepath.Join(dir, "city-1.json"), []LocationData{oslo, wrongProvince, rabat})
+	if err := os.WriteFile(filepath.Join(dir, "city-2.json"), []byte("[{"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	before := []float64{
+		metrics.filesProcessed.Value(),
+		metrics.recordsValid.Value("Norway"),
+		metrics.recordsInvalid.Value("Norway", reasonFieldMismatch),
+		metrics.recordsInvalid.Value("unknown", reasonUnknownKey), // Morocco is not in the reference data
+		metrics.filesUnprocessable.Value("syntax"),
+	}
+	if _, err := ProcessFileResults(dir, HelperUtils{readData, parseCityFile, GetUniqueKey, hardCheck, getAllFiles}); err != nil {
+		t.Fatal(err)
+	}
+	after := []float64{
+		metrics.filesProcessed.Value(),
+		metrics.recordsValid.Value("Norway"),
+		metrics.recordsInvalid.Value("Norway", reasonFieldMismatch),
+		metrics.recordsInvalid.Value("unknown", reasonUnknownKey), // Morocco is not in the reference data
+		metrics.filesUnprocessable.Value("syntax"),
+	}
+
+	for i, want := range []float64{2, 1, 1, 1, 1} {
+		if got := after[i] - before[i]; got != want {
+			t.Errorf("metric %d grew by %v, want %v", i, got, want)
+		}
+	}
+}
+
+func TestValidateFileMetricsCached(t *testing.T) {
+	useReference(map[Key]LocationData{GetUniqueKey(oslo): oslo}, "")
+	dir := t.TempDir()
+	writeReferenceFile(t, filepath.Join(dir, "city-1.json"), []LocationData{oslo, rabat})
+	if err := os.WriteFile(filepath.Join(dir, "city-2.json"), []byte("[{"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	cache, err := OpenResultCache(filepath.Join(t.TempDir(), "cache.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	helpers := HelperUtils{readData, parseCityFile, GetUniqueKey, hardCheck, getAllFiles}
+
+	values := func() []float64 {
+		return []float64{
+			metrics.filesProcessed.Value(),
+			metrics.recordsValid.Value("Norway"),
+			metrics.recordsInvalid.Value("unknown", reasonUnknownKey),
+			metrics.filesUnprocessable.Value("syntax"),
+			metrics.filesCached.Value("cache"),
+		}
+	}
+	// the run filling the cache, then the run served from it count the same
+	for run, wantCached := range []float64{0, 2} {
+		before := values()
+		if _, err := ProcessFileResultsWith(dir, helpers, RunOptions{Ordered: true, Cache: cache}); err != nil {
+			t.Fatal(err)
+		}
+		after := values()
+		for i, want := range []float64{2, 1, 1, 1, wantCached} {
+			if got := after[i] - before[i]; got != want {
+				t.Errorf("run %d: metric %d grew by %v, want %v", run, i, got, want)
+			}
+		}
+	}
+}
+
+func TestClassifyLoadError(t *testing.T) {
+	_, syntaxErr := loadDataToStruct(filepath.Join("tmp", "city-21.json"))
+	_, missingErr := loadDataToStruct(filepath.Join(t.TempDir(), "missing.json"))
+	typePath := filepath.Join(t.TempDir(), "type.json")
+	if err := os.WriteFile(typePath, []byte(`{"city": "Oslo"}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	_, typeErr := loadDataToStruct(typePath)
+
+	tests := []struct {
+		err  error
+		want string
+	}{
+		{syntaxErr, "syntax"},
+		{missingErr, "read"},
+		{typeErr, "type"},
+		{fmt.Errorf("wrapped: %w", syntaxErr), "syntax"},
+		{errors.New("something else"), "other"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.want, func(t *testing.T) {
+			if got := classifyLoadError(tt.err); got != tt.want {
+				t.Errorf("classifyLoadError(%v) = %q, want %q", tt.err, got, tt.want)
+			}
+		})
+	}
+}
@@ -9,36 +9,47 @@ type Key struct {
 	Geo     string
 }
 
-// GetUniqueKey take some of the fields and returns the key struct
+// GetUniqueKey take some of the fields and returns the key struct,
+// names are normalized with nameNormalization
 func GetUniqueKey(city LocationData) Key {
 	return Key{
-		City:    city.Name,
-		Country: city.Country,
+		City:    nameNormalization.Apply(city.Name),
+		Country: nameNormalization.Apply(city.Country),
 		Geo:     city.Geo,
 	}
 }
 
 // LocationData struct for the json data
 type LocationData struct {
-	Latitude     string `json:"latitude"`      // Latitude coordinate
-	Longitude    string `json:"longitude"`     // Longitude coordinate
-	Geo          string `json:"geo"`           // Geographical coordinates as a string
-	Name         string `json:"City"`          // City name
-	ProvinceIcon string `json:"province_icon"` // URL to the province icon (can be null)
-	Province     string `json:"province"`      // Province name (empty if not applicable)
-	CountryIcon  string `json:"country_icon"`  // URL to the country flag icon
-	Country      string `json:"country"`       // Country name
+	Latitude     string `json:"latitude"`             // Latitude coordinate
+	Longitude    string `json:"longitude"`            // Longitude coordinate
+	Geo          string `json:"geo"`                  // Geographical coordinates as a string
+	Name         string `json:"City"`                 // City name
+	ProvinceIcon string `json:"province_icon"`        // URL to the province icon (can be null)
+	Province     string `json:"province"`             // Province name (empty if not applicable)
+	CountryIcon  string `json:"country_icon"`         // URL to the country flag icon
+	Country      string `json:"country"`              // Country name
+	Hemisphere   string `json:"hemisphere,omitempty"` // Optional N/S/E/W markers, e.g. "SW"
+}
+
+// comparableRecord the fields records are compared on: names normalized and
+// Hemisphere left out, it only helps to resolve the coordinates
+func comparableRecord(city LocationData) LocationData {
+	city = normalizeNames(city)
+	city.Hemisphere = ""
+	return city
 }
 
 func hardCheck(verifyData, inp LocationData) bool {
-	return reflect.DeepEqual(verifyData, inp)
+	return reflect.DeepEqual(comparableRecord(verifyData), comparableRecord(inp))
 }
 
 func (inp LocationData) hardValidate(verifyData LocationData) bool {
-	return reflect.DeepEqual(verifyData, inp)
+	return reflect.DeepEqual(comparableRecord(verifyData), comparableRecord(inp))
 }
 
 func (inp LocationData) basicValidate(verifyData LocationData) bool {
+	inp, verifyData = comparableRecord(inp), comparableRecord(verifyData)
 	if inp.Name == verifyData.Name && inp.Geo == verifyData.Geo && inp.Country == verifyData.Country {
 		return true
 	}
@@ -301,3 +301,28 @@ func TestHardCheck(t *testing.T) {
 		})
 	}
 }
+
+func TestCompareIgnoresHemisphere(t *testing.T) {
+	reference, err := loadDataToStruct("tmp/city-1.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	verifyData := reference[0]
+	inp := verifyData
+	inp.Hemisphere = "NW"
+
+	if !hardCheck(verifyData, inp) {
+		t.Errorf("hardCheck() = false for %+v, want true", inp)
+	}
+	if !inp.hardValidate(verifyData) {
+		t.Errorf("hardValidate() = false for %+v, want true", inp)
+	}
+	if !inp.basicValidate(verifyData) {
+		t.Errorf("basicValidate() = false for %+v, want true", inp)
+	}
+
+	inp.Province = "Coahuila"
+	if hardCheck(verifyData, inp) {
+		t.Errorf("hardCheck() = true for %+v, want the province to differ", inp)
+	}
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+
+	"golang.org/x/text/cases"
+	"golang.org/x/text/runes"
+	"golang.org/x/text/transform"
+	"golang.org/x/text/unicode/norm"
+)
+
+// NormalizationForm Unicode normalization form applied to names
+type NormalizationForm string
+
+const (
+	FormNone NormalizationForm = "none"
+	FormNFC  NormalizationForm = "nfc"
+	FormNFKC NormalizationForm = "nfkc"
+)
+
+// NormalizeOptions controls how city, province and country names are
+// normalized before they are used in a Key or compared.
+type NormalizeOptions struct {
+	Form            NormalizationForm
+	CaseFold        bool // compare "LONDON" and "london" as equal
+	TrimSpace       bool // trim both ends and collapse inner runs of whitespace
+	StripDiacritics bool // compare "León" and "Leon" as equal
+}
+
+// DefaultNormalization only removes differences that are invisible to a
+// reader: composed vs decomposed accents and stray whitespace.
+var DefaultNormalization = NormalizeOptions{Form: FormNFC, TrimSpace: true}
+
+// nameNormalization options used by GetUniqueKey and the validators,
+// the reference file and the candidates always share them.
+var nameNormalization = DefaultNormalization
+
+// ParseNormalizationForm accepts "none", "nfc" or "nfkc" in any case.
+func ParseNormalizationForm(s string) (NormalizationForm, error) {
+	switch form := NormalizationForm(strings.ToLower(strings.TrimSpace(s))); form {
+	case FormNone, FormNFC, FormNFKC:
+		return form, nil
+	case "":
+		return FormNone, nil
+	default:
+		return "", fmt.Errorf("unknown normalization form %q", s)
+	}
+}
+
+// Apply normalizes s according to the options.
+func (o NormalizeOptions) Apply(s string) string {
+	var chain []transform.Transformer
+	if o.StripDiacritics {
+		chain = append(chain, norm.NFD, runes.Remove(runes.In(unicode.Mn)))
+	}
+	switch o.Form {
+	case FormNFC:
+		chain = append(chain, norm.NFC)
+	case FormNFKC:
+		chain = append(chain, norm.NFKC)
+	default:
+		if o.StripDiacritics {
+			chain = append(chain, norm.NFC)
+		}
+	}
+	if o.CaseFold {
+		chain = append(chain, cases.Fold())
+	}
+
+	if len(chain) > 0 {
+		// transformers keep state, so every call builds its own chain
+		if out, _, err := transform.String(transform.Chain(chain...), s); err == nil {
+			s = out
+		}
+	}
+	if o.TrimSpace {
+		s = strings.Join(strings.Fields(s), " ")
+	}
+	return s
+}
+
+// normalizeNames returns a copy of city with its name fields normalized
+func normalizeNames(city LocationData) LocationData {
+	city.Name = nameNormalization.Apply(city.Name)
+	city.Province = nameNormalization.Apply(city.Province)
+	city.Country = nameNormalization.Apply(city.Country)
+	return city
+}
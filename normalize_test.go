@@ -0,0 +1,80 @@
+package main
+
+import "testing"
+
+func TestNormalizeOptions_Apply(t *testing.T) {
+	const composed = "Nuevo León"    // ó as a single code point
+	const decomposed = "Nuevo León" // o followed by a combining acute accent
+
+	tests := []struct {
+		name string
+		opts NormalizeOptions
+		in   string
+		want string
+	}{
+		{"none keeps input", NormalizeOptions{Form: FormNone}, decomposed, decomposed},
+		{"nfc composes", NormalizeOptions{Form: FormNFC}, decomposed, composed},
+		{"nfkc folds compatibility characters", NormalizeOptions{Form: FormNFKC}, "ﬁle", "file"},
+		{"case folding", NormalizeOptions{Form: FormNFC, CaseFold: true}, "SÃO PAULO", "são paulo"},
+		{"trim and collapse whitespace", NormalizeOptions{TrimSpace: true}, "  Buenos \t Aires ", "Buenos Aires"},
+		{"strip diacritics", NormalizeOptions{StripDiacritics: true}, composed, "Nuevo Leon"},
+		{"strip diacritics from decomposed input", NormalizeOptions{Form: FormNFC, StripDiacritics: true}, decomposed, "Nuevo Leon"},
+		{"default", DefaultNormalization, " " + decomposed, composed},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.opts.Apply(tt.in); got != tt.want {
+				t.Errorf("Apply(%q) = %q, want %q", tt.in, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseNormalizationForm(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    NormalizationForm
+		wantErr bool
+	}{
+		{"nfc", FormNFC, false},
+		{"NFKC", FormNFKC, false},
+		{"none", FormNone, false},
+		{"", FormNone, false},
+		{"nfd", "", true},
+	}
+	for _, tt := range tests {
+		got, err := ParseNormalizationForm(tt.in)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ParseNormalizationForm(%q) error = %v, wantErr %v", tt.in, err, tt.wantErr)
+		}
+		if got != tt.want {
+			t.Errorf("ParseNormalizationForm(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestNormalizedKeyMatching(t *testing.T) {
+	reference := LocationData{Name: "Monterrey", Province: "Nuevo León", Country: "Mexico", Geo: "25.40, 100.18"}
+	candidate := LocationData{Name: " Monterrey", Province: "Nuevo León", Country: "Mexico", Geo: "25.40, 100.18"}
+
+	defer func(saved NormalizeOptions) { nameNormalization = saved }(nameNormalization)
+
+	nameNormalization = NormalizeOptions{Form: FormNone}
+	if GetUniqueKey(reference) == GetUniqueKey(candidate) || hardCheck(reference, candidate) {
+		t.Errorf("byte-exact matching should tell both records apart")
+	}
+
+	nameNormalization = DefaultNormalization
+	if GetUniqueKey(reference) != GetUniqueKey(candidate) {
+		t.Errorf("GetUniqueKey() = %+v and %+v, want equal keys", GetUniqueKey(reference), GetUniqueKey(candidate))
+	}
+	if !hardCheck(reference, candidate) || !candidate.basicValidate(reference) {
+		t.Errorf("normalized records should validate against each other")
+	}
+
+	nameNormalization = NormalizeOptions{Form: FormNFC, CaseFold: true, TrimSpace: true, StripDiacritics: true}
+	candidate.Name, candidate.Province = "MONTERREY", "Nuevo Leon"
+	if !hardCheck(reference, candidate) {
+		t.Errorf("case and accent insensitive matching should accept %+v", candidate)
+	}
+}
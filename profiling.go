@@ -0,0 +1,102 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"net/http"
+	"net/http/pprof"
+	"os"
+	"runtime"
+	rpprof "runtime/pprof"
+	"runtime/trace"
+)
+
+// profileFlags where a single run writes its CPU and heap profiles and its execution trace
+type profileFlags struct {
+	cpu, heap, trace *string
+}
+
+func addProfileFlags(fs *flag.FlagSet) *profileFlags {
+	return &profileFlags{
+		cpu:   fs.String("cpuprofile", "", "write a CPU profile of the run to this file"),
+		heap:  fs.String("memprofile", "", "write a heap profile to this file when the run ends"),
+		trace: fs.String("trace", "", "write a runtime execution trace of the run to this file"),
+	}
+}
+
+// start starts the CPU profile and the trace that were asked for. The returned
+// func stops them and writes the heap profile, it must be called once the work
+// to profile is done.
+func (f *profileFlags) start() (func() error, error) {
+	var stops []func() error
+	stop := func() error {
+		var errs []error
+		for i := len(stops) - 1; i >= 0; i-- {
+			errs = append(errs, stops[i]())
+		}
+		return errors.Join(errs...)
+	}
+
+	if *f.cpu != "" {
+		out, err := os.Create(*f.cpu)
+		if err != nil {
+			return nil, err
+		}
+		if err := rpprof.StartCPUProfile(out); err != nil {
+			out.Close()
+			return nil, fmt.Errorf("starting CPU profile: %w", err)
+		}
+		stops = append(stops, func() error {
+			rpprof.StopCPUProfile()
+			return out.Close()
+		})
+	}
+
+	if *f.trace != "" {
+		out, err := os.Create(*f.trace)
+		if err != nil {
+			_ = stop()
+			return nil, err
+		}
+		if err := trace.Start(out); err != nil {
+			out.Close()
+			_ = stop()
+			return nil, fmt.Errorf("starting trace: %w", err)
+		}
+		stops = append(stops, func() error {
+			trace.Stop()
+			return out.Close()
+		})
+	}
+
+	if *f.heap != "" {
+		path := *f.heap
+		stops = append(stops, func() error { return writeHeapProfile(path) })
+	}
+	return stop, nil
+}
+
+// writeHeapProfile writes the heap profile as of the last garbage collection
+func writeHeapProfile(path string) error {
+	out, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	runtime.GC() // up to date statistics of what is still allocated
+	if err := rpprof.WriteHeapProfile(out); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
+
+// registerPprof adds the net/http/pprof handlers under /debug/pprof/ to mux
+func registerPprof(mux *http.ServeMux) {
+	mux.HandleFunc("GET /debug/pprof/", pprof.Index)
+	mux.HandleFunc("GET /debug/pprof/cmdline", pprof.Cmdline)
+	mux.HandleFunc("GET /debug/pprof/profile", pprof.Profile)
+	mux.HandleFunc("GET /debug/pprof/symbol", pprof.Symbol)
+	mux.HandleFunc("POST /debug/pprof/symbol", pprof.Symbol)
+	mux.HandleFunc("GET /debug/pprof/trace", pprof.Trace)
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"flag"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestProfileFlags(t *testing.T) {
+	dir := t.TempDir()
+	cpu, heap, trace := filepath.Join(dir, "cpu.out"), filepath.Join(dir, "mem.out"), filepath.Join(dir, "trace.out")
+
+	tests := []struct {
+		name  string
+		args  []string
+		files []string
+	}{
+		{name: "none", args: nil, files: nil},
+		{name: "all", args: []string{"-cpuprofile", cpu, "-memprofile", heap, "-trace", trace}, files: []string{cpu, heap, trace}},
+		{name: "heap only", args: []string{"-memprofile", heap}, files: []string{heap}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for _, path := range []string{cpu, heap, trace} {
+				os.Remove(path)
+			}
+			fs := flag.NewFlagSet("test", flag.ContinueOnError)
+			profiles := addProfileFlags(fs)
+			if err := fs.Parse(tt.args); err != nil {
+				t.Fatal(err)
+			}
+
+			stop, err := profiles.start()
+			if err != nil {
+				t.Fatal(err)
+			}
+			ProcessFiles("tmp", HelperUtils{readData, parseCityFile, GetUniqueKey, hardCheck, getAllFiles})
+			if err := stop(); err != nil {
+				t.Fatal(err)
+			}
+
+			for _, path := range []string{cpu, heap, trace} {
+				info, err := os.Stat(path)
+				want := slices.Contains(tt.files, path)
+				if want && (err != nil || info.Size() == 0) {
+					t.Errorf("%s was not written: %v", filepath.Base(path), err)
+				}
+				if !want && err == nil {
+					t.Errorf("%s was written, want no file", filepath.Base(path))
+				}
+			}
+		})
+	}
+}
+
+func TestProfileFlagsCreateError(t *testing.T) {
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	profiles := addProfileFlags(fs)
+	if err := fs.Parse([]string{"-trace", filepath.Join(t.TempDir(), "missing", "trace.out")}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := profiles.start(); err == nil {
+		t.Error("start() error = nil, want an error for a trace in a missing folder")
+	}
+}
+
+func TestRegisterPprof(t *testing.T) {
+	mux := http.NewServeMux()
+	registerPprof(mux)
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	tests := []struct {
+		path string
+		want string
+	}{
+		{"/debug/pprof/", "goroutine"},
+		{"/debug/pprof/heap?debug=1", "heap profile"},
+		{"/debug/pprof/cmdline", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			resp, err := http.Get(server.URL + tt.path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			body, _ := io.ReadAll(resp.Body)
+			resp.Body.Close()
+			if resp.StatusCode != http.StatusOK {
+				t.Fatalf("GET %s = %d, want 200", tt.path, resp.StatusCode)
+			}
+			if !strings.Contains(string(body), tt.want) {
+				t.Errorf("GET %s = %.200s, want it to contain %q", tt.path, body, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,144 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+	"math"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Progress state of a run at one point in time
+type Progress struct {
+	Files            int
+	Total            int
+	Records          int
+	Elapsed          time.Duration
+	RecordsPerSecond float64
+	ETA              time.Duration // 0 until the first file is done
+}
+
+// String one line summary of p
+func (p Progress) String() string {
+	eta := "?"
+	if p.Files > 0 {
+		eta = p.ETA.Round(time.Second).String()
+	}
+	return fmt.Sprintf("files=%d/%d records=%d records_per_sec=%.1f elapsed=%s eta=%s",
+		p.Files, p.Total, p.Records, p.RecordsPerSecond, p.Elapsed.Round(time.Second), eta)
+}
+
+// ProgressReporter counts the files the workers finish and reports every
+// Interval, which must be positive. With Live it keeps rewriting a single
+// line on Out, as on a terminal, otherwise it logs one record per report to
+// Logger, or the default logger when it is nil. A nil ProgressReporter does
+// nothing.
+type ProgressReporter struct {
+	Out      io.Writer
+	Logger   *slog.Logger
+	Interval time.Duration
+	Live     bool
+
+	total   atomic.Int64
+	files   atomic.Int64
+	records atomic.Int64
+	started time.Time
+
+	stop chan struct{}
+	wg   sync.WaitGroup
+}
+
+// NewProgressReporter reporter writing to out, live when out is a terminal
+func NewProgressReporter(out *os.File, interval time.Duration) *ProgressReporter {
+	return &ProgressReporter{Out: out, Interval: interval, Live: isTerminal(out)}
+}
+
+func isTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+// Snapshot current progress
+func (p *ProgressReporter) Snapshot() Progress {
+	progress := Progress{
+		Files:   int(p.files.Load()),
+		Total:   int(p.total.Load()),
+		Records: int(p.records.Load()),
+		Elapsed: time.Since(p.started),
+	}
+	if seconds := progress.Elapsed.Seconds(); seconds > 0 {
+		progress.RecordsPerSecond = float64(progress.Records) / seconds
+	}
+	if progress.Files > 0 {
+		perFile := progress.Elapsed / time.Duration(progress.Files)
+		progress.ETA = perFile * time.Duration(progress.Total-progress.Files)
+	}
+	return progress
+}
+
+// begin starts reporting a run over total files
+func (p *ProgressReporter) begin(total int) {
+	if p == nil {
+		return
+	}
+	p.total.Store(int64(total))
+	p.files.Store(0)
+	p.records.Store(0)
+	p.started = time.Now()
+	p.stop = make(chan struct{})
+
+	p.wg.Add(1)
+	go func() {
+		defer p.wg.Done()
+		ticker := time.NewTicker(p.Interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-p.stop:
+				return
+			case <-ticker.C:
+				p.report()
+			}
+		}
+	}()
+}
+
+// fileDone called by the workers for every finished file
+func (p *ProgressReporter) fileDone(records int) {
+	if p == nil {
+		return
+	}
+	p.files.Add(1)
+	p.records.Add(int64(records))
+}
+
+// finish stops reporting and writes the final state
+func (p *ProgressReporter) finish() {
+	if p == nil {
+		return
+	}
+	close(p.stop)
+	p.wg.Wait()
+	p.report()
+	if p.Live {
+		fmt.Fprintln(p.Out)
+	}
+}
+
+func (p *ProgressReporter) report() {
+	if p.Live {
+		// \033[K clears what is left of a longer previous line
+		fmt.Fprintf(p.Out, "\r%s\033[K", p.Snapshot())
+		return
+	}
+	logger := p.Logger
+	if logger == nil {
+		logger = slog.Default()
+	}
+	progress := p.Snapshot()
+	logger.Info("progress", "files", progress.Files, "total", progress.Total, "records", progress.Records,
+		"records_per_sec", math.Round(progress.RecordsPerSecond*10)/10, "elapsed", progress.Elapsed.Round(time.Millisecond), "eta", progress.ETA.Round(time.Second))
+}
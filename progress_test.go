@@ -0,0 +1,87 @@
+package main
+
+import (
+	"bytes"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestProgressReporter(t *testing.T) {
+	useReference(map[Key]LocationData{GetUniqueKey(oslo): oslo}, "")
+	dir := t.TempDir()
+	writeReferenceFile(t, filepath.Join(dir, "city-1.json"), []LocationData{oslo, rabat})
+	writeReferenceFile(t, filepath.Join(dir, "city-2.json"), []LocationData{oslo})
+	helpers := HelperUtils{readData, parseCityFile, GetUniqueKey, hardCheck, getAllFiles}
+
+	tests := []struct {
+		name     string
+		live     bool
+		wantLast string
+	}{
+		{"log lines", false, "level=INFO msg=progress files=2 total=2 records=3 "},
+		{"live line", true, "\rfiles=2/2 records=3 "},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var out bytes.Buffer
+			progress := &ProgressReporter{Out: &out, Interval: time.Hour, Live: tt.live}
+			if !tt.live {
+				logger, err := newLogger(&out, "info", "text")
+				if err != nil {
+					t.Fatal(err)
+				}
+				progress.Logger = logger
+			}
+
+			if _, err := ProcessFileResultsWith(dir, helpers, RunOptions{Ordered: true, Progress: progress}); err != nil {
+				t.Fatal(err)
+			}
+
+			if !strings.Contains(out.String(), tt.wantLast) {
+				t.Errorf("progress output = %q, want it to contain %q", out.String(), tt.wantLast)
+			}
+			if tt.live != strings.HasSuffix(out.String(), "\033[K\n") {
+				t.Errorf("progress output = %q, live line not terminated as expected", out.String())
+			}
+		})
+	}
+}
+
+func TestProgressSnapshot(t *testing.T) {
+	progress := &ProgressReporter{started: time.Now().Add(-10 * time.Second)}
+	progress.total.Store(4)
+	progress.files.Store(1)
+	progress.records.Store(50)
+
+	got := progress.Snapshot()
+	if got.ETA.Round(time.Second) != 30*time.Second {
+		t.Errorf("Snapshot() ETA = %v, want 30s", got.ETA)
+	}
+	if got.RecordsPerSecond < 4.9 || got.RecordsPerSecond > 5.1 {
+		t.Errorf("Snapshot() RecordsPerSecond = %v, want 5", got.RecordsPerSecond)
+	}
+
+	progress.files.Store(0)
+	if s := progress.Snapshot().String(); !strings.HasSuffix(s, "eta=?") {
+		t.Errorf("Snapshot() before the first file = %q, want an unknown ETA", s)
+	}
+}
+
+func TestRunValidateProgressInterval(t *testing.T) {
+	keepDefaultLogger(t)
+	tests := [][]string{
+		{"-progress-interval", "0"},
+		{"-progress-interval", "-1s"},
+		// only the stream engine reports progress
+		{"-engine", "mutex", "-progress-interval", "1s"},
+	}
+	for _, args := range tests {
+		t.Run(strings.Join(args, " "), func(t *testing.T) {
+			if code := runValidate(args); code != 2 {
+				t.Errorf("runValidate(%v) = %d, want 2", args, code)
+			}
+		})
+	}
+}
@@ -0,0 +1,188 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// QuarantineStatus where a file was sorted to
+type QuarantineStatus string
+
+const (
+	StatusAccepted    QuarantineStatus = "accepted"
+	StatusInvalid     QuarantineStatus = "invalid"
+	StatusUnparseable QuarantineStatus = "unparseable"
+)
+
+// sidecarSuffix appended to the file name of the reasons written next to a quarantined file
+const sidecarSuffix = ".reasons.json"
+
+// QuarantineOptions destination folders, an empty folder leaves those files in place
+type QuarantineOptions struct {
+	InvalidDir     string
+	UnparseableDir string
+	AcceptedDir    string
+	Copy           bool // copy instead of move
+}
+
+// RejectedRecord why one record of a quarantined file failed
+type RejectedRecord struct {
+	Index      int          `json:"index"`
+	Record     LocationData `json:"record"`
+	Reason     string       `json:"reason"`
+	Fields     []string     `json:"fields,omitempty"` // fields that differ from the reference entry
+	Suggestion *Suggestion  `json:"suggestion,omitempty"`
+}
+
+// QuarantineReport outcome for one file, the sidecar of quarantined files holds it as JSON
+type QuarantineReport struct {
+	File              string           `json:"file"`
+	Destination       string           `json:"destination"`
+	Status            QuarantineStatus `json:"status"`
+	Copied            bool             `json:"copied,omitempty"` // the file was also left in place
+	ReferenceSnapshot string           `json:"reference_snapshot"`
+	Error             string           `json:"error,omitempty"`
+	Rejected          []RejectedRecord `json:"rejected,omitempty"`
+}
+
+// QuarantineFiles moves (or copies) every file of results to the folder of
+// its status and writes a sidecar with the reasons next to quarantined ones.
+// Files whose folder is not configured are left alone and not reported.
+func QuarantineFiles(results []FileResult, opts QuarantineOptions) ([]QuarantineReport, error) {
+	ref := loadedReference()
+	var reports []QuarantineReport
+	for _, result := range results {
+		report := QuarantineReport{File: result.Path, Copied: opts.Copy, ReferenceSnapshot: ref.snapshot}
+		var dir string
+		switch invalid := result.Invalid(); {
+		case result.Err != nil:
+			report.Status, dir = StatusUnparseable, opts.UnparseableDir
+			report.Error = result.Err.Error()
+		case len(invalid) > 0:
+			report.Status, dir = StatusInvalid, opts.InvalidDir
+			report.Rejected = ref.rejectedRecords(invalid)
+		default:
+			report.Status, dir = StatusAccepted, opts.AcceptedDir
+		}
+		if dir == "" {
+			continue
+		}
+
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			return reports, err
+		}
+		destination, err := transferToDir(result.Path, dir, opts.Copy)
+		if err != nil {
+			return reports, err
+		}
+		report.Destination = destination
+
+		if report.Status != StatusAccepted {
+			data, err := json.MarshalIndent(report, "", "  ")
+			if err != nil {
+				return reports, err
+			}
+			if err := os.WriteFile(report.Destination+sidecarSuffix, data, 0o644); err != nil {
+				return reports, err
+			}
+		}
+		reports = append(reports, report)
+	}
+	return reports, nil
+}
+
+// rejectedRecords explains every invalid record
+func (reference *referenceData) rejectedRecords(invalid []RecordResult) []RejectedRecord {
+	rejected := make([]RejectedRecord, 0, len(invalid))
+	for _, record := range invalid {
+		r := RejectedRecord{Index: record.Index, Record: record.Record}
+
+		if ref, ok := reference.lookup(GetUniqueKey(record.Record)); ok {
+			r.Reason = "differs from the reference entry"
+			for _, field := range changedFields(comparableRecord(ref), comparableRecord(record.Record)) {
+				r.Fields = append(r.Fields, field.Field)
+			}
+		} else {
+			r.Reason = "no reference entry with this city, country and geo"
+		}
+
+		if suggestion, ok := reference.suggest(record.Record); ok {
+			r.Suggestion = &suggestion
+		}
+		rejected = append(rejected, r)
+	}
+	return rejected
+}
+
+// maxNameCollisions how many numbered names transferToDir tries
+const maxNameCollisions = 1000
+
+// transferToDir moves (or copies, with keep) src into dir and returns where it
+// ended up. A file of the same name already in dir, e.g. from an earlier run,
+// is never replaced: src gets the first free name city-1-1.json, city-1-2.json, ...
+func transferToDir(src, dir string, keep bool) (string, error) {
+	base := filepath.Base(src)
+	ext := filepath.Ext(base)
+	for n := 0; n < maxNameCollisions; n++ {
+		dst := filepath.Join(dir, base)
+		if n > 0 {
+			dst = filepath.Join(dir, fmt.Sprintf("%s-%d%s", strings.TrimSuffix(base, ext), n, ext))
+		}
+		if _, err := os.Lstat(dst + sidecarSuffix); err == nil {
+			continue // the sidecar of a file that is gone
+		}
+		err := transferFile(src, dst, keep)
+		if errors.Is(err, fs.ErrExist) {
+			continue
+		}
+		return dst, err
+	}
+	return "", fmt.Errorf("%s: no free name in %s", base, dir)
+}
+
+// transferFile moves src to dst, falling back to copy and remove across file
+// systems. It fails with fs.ErrExist instead of replacing an existing dst.
+func transferFile(src, dst string, keep bool) error {
+	if !keep {
+		// a hard link and remove is a rename that does not replace dst
+		switch err := os.Link(src, dst); {
+		case err == nil:
+			return os.Remove(src)
+		case errors.Is(err, fs.ErrExist):
+			return err
+		}
+	}
+
+	if err := copyFile(src, dst); err != nil {
+		return err
+	}
+	if keep {
+		return nil
+	}
+	return os.Remove(src)
+}
+
+// copyFile copies src to the new file dst, an existing dst is an fs.ErrExist error
+func copyFile(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o644)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
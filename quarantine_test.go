@@ -0,0 +1,187 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestQuarantineFiles(t *testing.T) {
+	useReference(map[Key]LocationData{GetUniqueKey(oslo): oslo}, "")
+	wrongProvince := oslo
+	wrongProvince.Province = "Viken"
+
+	tests := []struct {
+		name       string
+		result     FileResult
+		copy       bool
+		wantDir    string
+		wantStatus QuarantineStatus
+		wantReason string
+	}{
+		{
+			name:       "unparseable",
+			result:     FileResult{Err: errors.New("invalid character")},
+			wantDir:    "unparseable",
+			wantStatus: StatusUnparseable,
+		},
+		{
+			name:       "unknown key",
+			result:     FileResult{Records: []RecordResult{{Index: 0, Record: oslo, Valid: true}, {Index: 1, Record: rabat}}},
+			wantDir:    "invalid",
+			wantStatus: StatusInvalid,
+			wantReason: "no reference entry with this city, country and geo",
+		},
+		{
+			name:       "field mismatch copied",
+			result:     FileResult{Records: []RecordResult{{Index: 1, Record: wrongProvince}}},
+			copy:       true,
+			wantDir:    "invalid",
+			wantStatus: StatusInvalid,
+			wantReason: "differs from the reference entry",
+		},
+		{
+			name:       "accepted",
+			result:     FileResult{Records: []RecordResult{{Index: 0, Record: oslo, Valid: true}}},
+			wantDir:    "accepted",
+			wantStatus: StatusAccepted,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			tt.result.Path = filepath.Join(dir, "city-1.json")
+			if err := os.WriteFile(tt.result.Path, []byte("[]"), 0o644); err != nil {
+				t.Fatal(err)
+			}
+			opts := QuarantineOptions{
+				InvalidDir:     filepath.Join(dir, "invalid"),
+				UnparseableDir: filepath.Join(dir, "unparseable"),
+				AcceptedDir:    filepath.Join(dir, "accepted"),
+				Copy:           tt.copy,
+			}
+
+			reports, err := QuarantineFiles([]FileResult{tt.result}, opts)
+			if err != nil {
+				t.Fatalf("QuarantineFiles() error = %v", err)
+			}
+			if len(reports) != 1 {
+				t.Fatalf("QuarantineFiles() returned %d reports, want 1", len(reports))
+			}
+			report := reports[0]
+
+			wantDestination := filepath.Join(dir, tt.wantDir, "city-1.json")
+			if report.Status != tt.wantStatus || report.Destination != wantDestination {
+				t.Errorf("QuarantineFiles() = %s to %s, want %s to %s", report.Status, report.Destination, tt.wantStatus, wantDestination)
+			}
+			if _, err := os.Stat(wantDestination); err != nil {
+				t.Errorf("destination missing: %v", err)
+			}
+			if _, err := os.Stat(tt.result.Path); (err == nil) != tt.copy {
+				t.Errorf("source kept = %v, want %v", err == nil, tt.copy)
+			}
+			var text bytes.Buffer
+			if err := WriteReport(&text, TextReport, Report{Quarantine: reports}); err != nil {
+				t.Fatal(err)
+			}
+			wantLine := "Moved " + tt.result.Path
+			if tt.copy {
+				wantLine = "Copied " + tt.result.Path
+			}
+			if report.Copied != tt.copy || !strings.Contains(text.String(), wantLine) {
+				t.Errorf("report copied = %v, text %q, want %q", report.Copied, text.String(), wantLine)
+			}
+
+			data, err := os.ReadFile(wantDestination + sidecarSuffix)
+			if tt.wantStatus == StatusAccepted {
+				if err == nil {
+					t.Errorf("sidecar written for an accepted file")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("sidecar missing: %v", err)
+			}
+			var sidecar QuarantineReport
+			if err := json.Unmarshal(data, &sidecar); err != nil {
+				t.Fatal(err)
+			}
+			if tt.wantReason == "" {
+				if sidecar.Error == "" {
+					t.Errorf("sidecar = %+v, want the parse error", sidecar)
+				}
+				return
+			}
+			if len(sidecar.Rejected) != 1 || sidecar.Rejected[0].Index != 1 || sidecar.Rejected[0].Reason != tt.wantReason {
+				t.Errorf("sidecar rejected = %+v, want record 1 with reason %q", sidecar.Rejected, tt.wantReason)
+			}
+		})
+	}
+}
+
+func TestQuarantineFilesNameCollision(t *testing.T) {
+	useReference(map[Key]LocationData{GetUniqueKey(oslo): oslo}, "")
+	dir := t.TempDir()
+	invalidDir := filepath.Join(dir, "invalid")
+	if err := os.MkdirAll(invalidDir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	// left by an earlier run: a file with its sidecar, and a sidecar alone
+	for name, content := range map[string]string{
+		"city-1.json":                   "earlier",
+		"city-1.json" + sidecarSuffix:   "earlier reasons",
+		"city-1-1.json" + sidecarSuffix: "orphaned reasons",
+	} {
+		if err := os.WriteFile(filepath.Join(invalidDir, name), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	path := filepath.Join(dir, "city-1.json")
+	if err := os.WriteFile(path, []byte("[]"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	reports, err := QuarantineFiles([]FileResult{{Path: path, Records: []RecordResult{{Record: rabat}}}}, QuarantineOptions{InvalidDir: invalidDir})
+	if err != nil || len(reports) != 1 {
+		t.Fatalf("QuarantineFiles() = %v, %v", reports, err)
+	}
+
+	want := filepath.Join(invalidDir, "city-1-2.json")
+	if reports[0].Destination != want {
+		t.Errorf("destination = %s, want %s", reports[0].Destination, want)
+	}
+	if data, err := os.ReadFile(want); err != nil || string(data) != "[]" {
+		t.Errorf("moved file = %q, %v", data, err)
+	}
+	if _, err := os.Stat(want + sidecarSuffix); err != nil {
+		t.Errorf("sidecar missing: %v", err)
+	}
+	for name, content := range map[string]string{
+		"city-1.json":                   "earlier",
+		"city-1.json" + sidecarSuffix:   "earlier reasons",
+		"city-1-1.json" + sidecarSuffix: "orphaned reasons",
+	} {
+		if data, err := os.ReadFile(filepath.Join(invalidDir, name)); err != nil || string(data) != content {
+			t.Errorf("%s replaced: %q, %v", name, data, err)
+		}
+	}
+}
+
+func TestQuarantineFilesUnconfigured(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "city-1.json")
+	if err := os.WriteFile(path, []byte("[]"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	reports, err := QuarantineFiles([]FileResult{{Path: path}}, QuarantineOptions{})
+	if err != nil || len(reports) != 0 {
+		t.Fatalf("QuarantineFiles() = %v, %v, want nothing moved", reports, err)
+	}
+	if _, err := os.Stat(path); err != nil {
+		t.Errorf("file moved although no folder was configured: %v", err)
+	}
+}
@@ -0,0 +1,241 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+	"sort"
+	"strings"
+)
+
+// ReferenceIssueKind category of a problem found in the reference data
+type ReferenceIssueKind string
+
+const (
+	IssueDuplicateKey           ReferenceIssueKind = "duplicate_key"
+	IssueConflictingCoordinates ReferenceIssueKind = "conflicting_coordinates"
+	IssueMalformedCoordinates   ReferenceIssueKind = "malformed_coordinates"
+	IssueOutsideCountry         ReferenceIssueKind = "outside_country"
+	IssueMissingField           ReferenceIssueKind = "missing_field"
+)
+
+// ReferenceIssue single finding, Indexes point into the array of the checked file
+type ReferenceIssue struct {
+	Kind    ReferenceIssueKind `json:"kind"`
+	Message string             `json:"message"`
+	Indexes []int              `json:"indexes"`
+	Entries []LocationData     `json:"entries"`
+}
+
+// ReferenceReport result of CheckReference
+type ReferenceReport struct {
+	File       string           `json:"file"`
+	Entries    int              `json:"entries"`
+	UniqueKeys int              `json:"unique_keys"`
+	Issues     []ReferenceIssue `json:"issues"`
+}
+
+// OK reports whether the reference data is free of issues
+func (r ReferenceReport) OK() bool {
+	return len(r.Issues) == 0
+}
+
+// Counts number of issues per kind
+func (r ReferenceReport) Counts() map[ReferenceIssueKind]int {
+	counts := make(map[ReferenceIssueKind]int)
+	for _, issue := range r.Issues {
+		counts[issue.Kind]++
+	}
+	return counts
+}
+
+// CheckReference looks for duplicate keys, cities sharing a name and country
+// with different coordinates, malformed or misplaced coordinates and empty
+// required fields. Issues are ordered by kind and by position in the file.
+func CheckReference(cities []LocationData, getUniqueKeyFunc func(data LocationData) Key) ReferenceReport {
+	issues := []ReferenceIssue{}
+
+	byKey := make(map[Key][]int, len(cities))
+	var keyOrder []Key
+	type nameKey struct{ city, country string }
+	byName := make(map[nameKey][]int, len(cities))
+	var nameOrder []nameKey
+
+	for i, city := range cities {
+		if missing := missingFields(city); len(missing) > 0 {
+			issues = append(issues, ReferenceIssue{
+				Kind:    IssueMissingField,
+				Message: "empty " + strings.Join(missing, ", "),
+				Indexes: []int{i},
+				Entries: []LocationData{city},
+			})
+		}
+
+		if city.Latitude != "" && city.Longitude != "" {
+			if msg := coordinateProblem(city); msg != "" {
+				issues = append(issues, ReferenceIssue{Kind: IssueMalformedCoordinates, Message: msg, Indexes: []int{i}, Entries: []LocationData{city}})
+			} else if _, err := ResolveGeoPoint(city); err != nil && !errors.Is(err, ErrHemisphereAmbiguous) {
+				issues = append(issues, ReferenceIssue{Kind: IssueOutsideCountry, Message: err.Error(), Indexes: []int{i}, Entries: []LocationData{city}})
+			}
+		}
+
+		key := getUniqueKeyFunc(city)
+		if _, ok := byKey[key]; !ok {
+			keyOrder = append(keyOrder, key)
+		}
+		byKey[key] = append(byKey[key], i)
+
+		nk := nameKey{key.City, key.Country}
+		if _, ok := byName[nk]; !ok {
+			nameOrder = append(nameOrder, nk)
+		}
+		byName[nk] = append(byName[nk], i)
+	}
+
+	for _, key := range keyOrder {
+		if indexes := byKey[key]; len(indexes) > 1 {
+			issues = append(issues, ReferenceIssue{
+				Kind:    IssueDuplicateKey,
+				Message: fmt.Sprintf("%d entries for %s, %s (%s)", len(indexes), key.City, key.Country, key.Geo),
+				Indexes: indexes,
+				Entries: entriesAt(cities, indexes),
+			})
+		}
+	}
+
+	for _, nk := range nameOrder {
+		indexes := byName[nk]
+		geos := make(map[string]bool)
+		for _, i := range indexes {
+			geos[cities[i].Geo] = true
+		}
+		if len(geos) > 1 {
+			issues = append(issues, ReferenceIssue{
+				Kind:    IssueConflictingCoordinates,
+				Message: fmt.Sprintf("%s, %s has %d different coordinates", nk.city, nk.country, len(geos)),
+				Indexes: indexes,
+				Entries: entriesAt(cities, indexes),
+			})
+		}
+	}
+
+	sort.SliceStable(issues, func(i, j int) bool {
+		if issues[i].Kind != issues[j].Kind {
+			return issues[i].Kind < issues[j].Kind
+		}
+		return issues[i].Indexes[0] < issues[j].Indexes[0]
+	})
+
+	return ReferenceReport{Entries: len(cities), UniqueKeys: len(byKey), Issues: issues}
+}
+
+func missingFields(city LocationData) []string {
+	var missing []string
+	for _, f := range []struct {
+		name  string
+		value string
+	}{
+		{"city", city.Name},
+		{"country", city.Country},
+		{"latitude", city.Latitude},
+		{"longitude", city.Longitude},
+		{"geo", city.Geo},
+		{"country_icon", city.CountryIcon},
+	} {
+		if strings.TrimSpace(f.value) == "" {
+			missing = append(missing, f.name)
+		}
+	}
+	return missing
+}
+
+// coordinateProblem describes what is wrong with the coordinates of city, "" if nothing
+func coordinateProblem(city LocationData) string {
+	lat, _, err := parseCoordinate(city.Latitude, 'N', 'S')
+	if err != nil {
+		return err.Error()
+	}
+	lon, _, err := parseCoordinate(city.Longitude, 'E', 'W')
+	if err != nil {
+		return err.Error()
+	}
+	if lat < -90 || lat > 90 || lon < -180 || lon > 180 {
+		return fmt.Sprintf("coordinates %s, %s out of range", city.Latitude, city.Longitude)
+	}
+	if want := city.Latitude + ", " + city.Longitude; city.Geo != want {
+		return fmt.Sprintf("geo %q does not match latitude/longitude %q", city.Geo, want)
+	}
+	return ""
+}
+
+func entriesAt(cities []LocationData, indexes []int) []LocationData {
+	entries := make([]LocationData, len(indexes))
+	for i, idx := range indexes {
+		entries[i] = cities[idx]
+	}
+	return entries
+}
+
+// runReferenceCommand handles `reference check|diff [flags] ...`
+func runReferenceCommand(args []string) int {
+	const usage = "usage: reference check [flags] [file] | reference diff [flags] old.json new.json"
+	if len(args) == 0 {
+		fmt.Fprintln(os.Stderr, usage)
+		return 2
+	}
+
+	fs := flag.NewFlagSet("reference "+args[0], flag.ExitOnError)
+	applyNormalization := addNormalizationFlags(fs)
+	applyLogging := addLogFlags(fs)
+	_ = fs.Parse(args[1:])
+	if err := applyLogging(); err != nil {
+		slog.Error("parsing flags", "err", err)
+		return 2
+	}
+	if err := applyNormalization(); err != nil {
+		slog.Error("parsing flags", "err", err)
+		return 2
+	}
+
+	switch args[0] {
+	case "check":
+		return runReferenceCheck(fs.Args())
+	case "diff":
+		return runReferenceDiff(fs.Args())
+	default:
+		fmt.Fprintln(os.Stderr, usage)
+		return 2
+	}
+}
+
+// runReferenceCheck handles `reference check [file]`, it exits with 1 when issues are found
+func runReferenceCheck(args []string) int {
+	file := "cities.json"
+	if len(args) > 0 {
+		file = args[0]
+	}
+
+	cities, err := loadDataToStruct(file)
+	if err != nil {
+		slog.Error("loading reference file", "file", file, "err", err)
+		return 2
+	}
+
+	report := CheckReference(cities, GetUniqueKey)
+	report.File = file
+
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(report); err != nil {
+		slog.Error("writing report", "err", err)
+		return 2
+	}
+
+	if !report.OK() {
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestCheckReference(t *testing.T) {
+	valid := LocationData{Latitude: "59.57", Longitude: "10.45", Geo: "59.57, 10.45", Name: "Oslo", Country: "Norway", CountryIcon: "no.png"}
+	duplicate := valid
+	duplicate.Province = "Oslo"
+	sameName := LocationData{Latitude: "32.47", Longitude: "79.56", Geo: "32.47, 79.56", Name: "Charleston", Country: "United States", CountryIcon: "us.png"}
+	sameNameElsewhere := LocationData{Latitude: "38.21", Longitude: "81.38", Geo: "38.21, 81.38", Name: "Charleston", Country: "United States", CountryIcon: "us.png"}
+	malformed := LocationData{Latitude: "12.a", Longitude: "10.00", Geo: "12.a, 10.00", Name: "Broken", Country: "Norway", CountryIcon: "no.png"}
+	geoMismatch := LocationData{Latitude: "60.23", Longitude: "5.20", Geo: "60.23, 5.2", Name: "Bergen", Country: "Norway", CountryIcon: "no.png"}
+	outside := LocationData{Latitude: "11.38", Longitude: "79.42", Geo: "11.38, 79.42", Name: "Palmas", Country: "Brazil", CountryIcon: "br.png"}
+	empty := LocationData{Latitude: "63.26", Longitude: "10.24", Geo: "63.26, 10.24", Country: "Norway"}
+
+	cities := []LocationData{valid, duplicate, sameName, sameNameElsewhere, malformed, geoMismatch, outside, empty}
+	report := CheckReference(cities, GetUniqueKey)
+
+	if report.Entries != len(cities) {
+		t.Errorf("Entries = %d, want %d", report.Entries, len(cities))
+	}
+	if report.UniqueKeys != len(cities)-1 {
+		t.Errorf("UniqueKeys = %d, want %d", report.UniqueKeys, len(cities)-1)
+	}
+	if report.OK() {
+		t.Fatalf("OK() = true, want issues")
+	}
+
+	want := []struct {
+		kind    ReferenceIssueKind
+		indexes []int
+	}{
+		{IssueConflictingCoordinates, []int{2, 3}},
+		{IssueDuplicateKey, []int{0, 1}},
+		{IssueMalformedCoordinates, []int{4}},
+		{IssueMalformedCoordinates, []int{5}},
+		{IssueMissingField, []int{7}},
+		{IssueOutsideCountry, []int{6}},
+	}
+	if len(report.Issues) != len(want) {
+		t.Fatalf("got %d issues, want %d: %+v", len(report.Issues), len(want), report.Issues)
+	}
+	for i, w := range want {
+		got := report.Issues[i]
+		if got.Kind != w.kind || !reflect.DeepEqual(got.Indexes, w.indexes) {
+			t.Errorf("Issues[%d] = %s %v, want %s %v", i, got.Kind, got.Indexes, w.kind, w.indexes)
+		}
+		if len(got.Entries) != len(got.Indexes) {
+			t.Errorf("Issues[%d] has %d entries for %d indexes", i, len(got.Entries), len(got.Indexes))
+		}
+	}
+	if got := report.Issues[1].Entries; got[0] != valid || got[1] != duplicate {
+		t.Errorf("duplicate issue should list both entries, got %+v", got)
+	}
+	if got := report.Counts()[IssueMalformedCoordinates]; got != 2 {
+		t.Errorf("Counts()[malformed] = %d, want 2", got)
+	}
+}
+
+func TestCheckReference_Clean(t *testing.T) {
+	oslo := LocationData{Latitude: "59.57", Longitude: "10.45", Geo: "59.57, 10.45", Name: "Oslo", Country: "Norway", CountryIcon: "no.png"}
+	report := CheckReference([]LocationData{oslo}, GetUniqueKey)
+	if !report.OK() || report.Issues == nil {
+		t.Errorf("CheckReference() = %+v, want an empty issue list", report)
+	}
+}
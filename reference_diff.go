@@ -0,0 +1,172 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"reflect"
+	"strings"
+)
+
+// FieldChange one field that differs between two versions of an entry
+type FieldChange struct {
+	Field string `json:"field"`
+	Old   string `json:"old"`
+	New   string `json:"new"`
+}
+
+// EntryChange entry present in both versions with different field values
+type EntryChange struct {
+	Key    Key           `json:"key"`
+	Old    LocationData  `json:"old"`
+	New    LocationData  `json:"new"`
+	Fields []FieldChange `json:"fields"`
+}
+
+// ReferenceDiff difference between two reference snapshots, keyed by GetUniqueKey
+type ReferenceDiff struct {
+	OldSnapshot string         `json:"old_snapshot"`
+	NewSnapshot string         `json:"new_snapshot"`
+	Added       []LocationData `json:"added"`
+	Removed     []LocationData `json:"removed"`
+	Changed     []EntryChange  `json:"changed"`
+}
+
+// Empty reports whether both snapshots hold the same entries
+func (d ReferenceDiff) Empty() bool {
+	return len(d.Added) == 0 && len(d.Removed) == 0 && len(d.Changed) == 0
+}
+
+// snapshotHash content hash of the reference entries. It is computed from the
+// decoded records, so reformatting the file does not change it.
+func snapshotHash(cities []LocationData) string {
+	h := sha256.New()
+	enc := json.NewEncoder(h)
+	for _, city := range cities {
+		_ = enc.Encode(city)
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// registrySnapshot snapshot of what a load kept: the entries left after
+// duplicates were resolved and, when sources is set, the source of each. A
+// single file without duplicates gets the snapshot its file has in a diff.
+func registrySnapshot(entries []LocationData, sources []string) string {
+	if sources == nil {
+		return snapshotHash(entries)
+	}
+	h := sha256.New()
+	enc := json.NewEncoder(h)
+	for i, city := range entries {
+		_ = enc.Encode(city)
+		fmt.Fprintln(h, sources[i])
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// DiffReference compares two versions of the reference data. Entries are
+// matched by key, the first entry wins when a key appears twice. Added and
+// changed entries follow the order of newCities, removed ones that of oldCities.
+func DiffReference(oldCities, newCities []LocationData, getUniqueKeyFunc func(data LocationData) Key) ReferenceDiff {
+	diff := ReferenceDiff{
+		OldSnapshot: snapshotHash(oldCities),
+		NewSnapshot: snapshotHash(newCities),
+		Added:       []LocationData{},
+		Removed:     []LocationData{},
+		Changed:     []EntryChange{},
+	}
+
+	oldByKey := make(map[Key]LocationData, len(oldCities))
+	for _, city := range oldCities {
+		if key := getUniqueKeyFunc(city); !hasKey(oldByKey, key) {
+			oldByKey[key] = city
+		}
+	}
+
+	seen := make(map[Key]LocationData, len(newCities))
+	for _, city := range newCities {
+		key := getUniqueKeyFunc(city)
+		if hasKey(seen, key) {
+			continue
+		}
+		seen[key] = city
+
+		old, ok := oldByKey[key]
+		if !ok {
+			diff.Added = append(diff.Added, city)
+			continue
+		}
+		if fields := changedFields(old, city); len(fields) > 0 {
+			diff.Changed = append(diff.Changed, EntryChange{Key: key, Old: old, New: city, Fields: fields})
+		}
+	}
+
+	for _, city := range oldCities {
+		key := getUniqueKeyFunc(city)
+		if old := oldByKey[key]; old != city {
+			continue // later duplicate of a key already handled
+		}
+		if !hasKey(seen, key) {
+			diff.Removed = append(diff.Removed, city)
+			seen[key] = city
+		}
+	}
+
+	return diff
+}
+
+func hasKey(m map[Key]LocationData, key Key) bool {
+	_, ok := m[key]
+	return ok
+}
+
+// changedFields lists the fields of old and new that differ, named after their JSON tags
+func changedFields(old, new LocationData) []FieldChange {
+	var fields []FieldChange
+	o, n := reflect.ValueOf(old), reflect.ValueOf(new)
+	for i := 0; i < o.NumField(); i++ {
+		if o.Field(i).String() == n.Field(i).String() {
+			continue
+		}
+		name := strings.Split(o.Type().Field(i).Tag.Get("json"), ",")[0]
+		fields = append(fields, FieldChange{Field: name, Old: o.Field(i).String(), New: n.Field(i).String()})
+	}
+	return fields
+}
+
+// runReferenceDiff handles `reference diff old.json new.json`, it exits with 1
+// when the files differ like diff(1) does
+func runReferenceDiff(args []string) int {
+	if len(args) != 2 {
+		fmt.Fprintln(os.Stderr, "usage: reference diff [flags] old.json new.json")
+		return 2
+	}
+
+	oldCities, err := loadDataToStruct(args[0])
+	if err != nil {
+		slog.Error("loading reference file", "file", args[0], "err", err)
+		return 2
+	}
+	newCities, err := loadDataToStruct(args[1])
+	if err != nil {
+		slog.Error("loading reference file", "file", args[1], "err", err)
+		return 2
+	}
+
+	diff := DiffReference(oldCities, newCities, GetUniqueKey)
+
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(diff); err != nil {
+		slog.Error("writing report", "err", err)
+		return 2
+	}
+
+	if !diff.Empty() {
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestDiffReference(t *testing.T) {
+	oslo := LocationData{Name: "Oslo", Country: "Norway", Geo: "59.57, 10.45", Province: "Oslo"}
+	bergen := LocationData{Name: "Bergen", Country: "Norway", Geo: "60.23, 5.20"}
+	tromso := LocationData{Name: "Tromsø", Country: "Norway", Geo: "69.41, 18.57"}
+
+	osloFixed := oslo
+	osloFixed.Province = "Oslo county"
+	osloFixed.CountryIcon = "no.png"
+
+	diff := DiffReference(
+		[]LocationData{oslo, bergen, bergen},
+		[]LocationData{tromso, osloFixed},
+		GetUniqueKey,
+	)
+
+	if !reflect.DeepEqual(diff.Added, []LocationData{tromso}) {
+		t.Errorf("Added = %+v", diff.Added)
+	}
+	if !reflect.DeepEqual(diff.Removed, []LocationData{bergen}) {
+		t.Errorf("Removed = %+v", diff.Removed)
+	}
+	if len(diff.Changed) != 1 {
+		t.Fatalf("Changed = %+v", diff.Changed)
+	}
+	want := []FieldChange{
+		{Field: "province", Old: "Oslo", New: "Oslo county"},
+		{Field: "country_icon", Old: "", New: "no.png"},
+	}
+	if got := diff.Changed[0]; got.Key != GetUniqueKey(oslo) || !reflect.DeepEqual(got.Fields, want) {
+		t.Errorf("Changed[0] = %+v, want fields %+v", got, want)
+	}
+	if diff.OldSnapshot == diff.NewSnapshot || diff.Empty() {
+		t.Errorf("snapshots of different data should differ")
+	}
+}
+
+func TestDiffReference_Same(t *testing.T) {
+	cities := []LocationData{{Name: "Oslo", Country: "Norway", Geo: "59.57, 10.45"}}
+	diff := DiffReference(cities, cities, GetUniqueKey)
+	if !diff.Empty() || diff.OldSnapshot != diff.NewSnapshot {
+		t.Errorf("DiffReference() of identical data = %+v", diff)
+	}
+	if diff.Added == nil || diff.Removed == nil || diff.Changed == nil {
+		t.Errorf("empty diff should encode as empty lists")
+	}
+}
+
+func TestSnapshotHash(t *testing.T) {
+	a := []LocationData{{Name: "Oslo"}, {Name: "Bergen"}}
+	b := []LocationData{{Name: "Bergen"}, {Name: "Oslo"}}
+	if snapshotHash(a) != snapshotHash(a) {
+		t.Errorf("snapshotHash() is not deterministic")
+	}
+	if snapshotHash(a) == snapshotHash(b) {
+		t.Errorf("snapshotHash() should depend on the order of the entries")
+	}
+}
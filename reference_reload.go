@@ -0,0 +1,197 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/signal"
+	"reflect"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// ReloadResult outcome of a successful reference reload
+type ReloadResult struct {
+	Version    int       `json:"version"`
+	Snapshot   string    `json:"snapshot"` // content hash of the entries
+	Sources    []string  `json:"sources"`
+	Checksum   string    `json:"checksum"` // hash of the file bytes of all sources
+	Entries    int       `json:"entries"`
+	Duplicates int       `json:"duplicates"`
+	LoadedAt   time.Time `json:"loaded_at"`
+}
+
+// fileStamp size and mtime of a source seen at the last reload
+type fileStamp struct {
+	modTime time.Time
+	size    int64
+}
+
+// ReferenceReloader keeps the reference data in sync with its source files.
+// A reload builds the complete new data before publishing it, a failed
+// reload keeps serving the previous data.
+type ReferenceReloader struct {
+	Paths  []string // reference layers, later ones take precedence
+	Policy DuplicatePolicy
+
+	loadDataFunc     func(string) ([]LocationData, error)
+	getUniqueKeyFunc func(data LocationData) Key
+
+	mu       sync.Mutex
+	stamps   map[string]fileStamp
+	checksum string
+	last     ReloadResult
+}
+
+// NewReferenceReloader reloader for the reference layers at paths
+func NewReferenceReloader(policy DuplicatePolicy, paths ...string) *ReferenceReloader {
+	return &ReferenceReloader{
+		Paths:            paths,
+		Policy:           policy,
+		loadDataFunc:     loadDataToStruct,
+		getUniqueKeyFunc: GetUniqueKey,
+	}
+}
+
+// Reload loads the files unconditionally and swaps the new data in.
+func (r *ReferenceReloader) Reload() (ReloadResult, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.reload()
+}
+
+// ReloadIfChanged reloads when the size or mtime of any source changed and
+// their checksum differs from the one loaded last. It reports whether it reloaded.
+func (r *ReferenceReloader) ReloadIfChanged() (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	stamps, err := r.stat()
+	if err != nil {
+		return false, err
+	}
+	if reflect.DeepEqual(stamps, r.stamps) {
+		return false, nil
+	}
+
+	checksum, err := r.sourcesChecksum()
+	if err != nil {
+		return false, err
+	}
+	if checksum == r.checksum {
+		r.stamps = stamps
+		return false, nil
+	}
+
+	_, err = r.reload()
+	return err == nil, err
+}
+
+// Last result of the most recent successful reload
+func (r *ReferenceReloader) Last() ReloadResult {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.last
+}
+
+func (r *ReferenceReloader) reload() (ReloadResult, error) {
+	stamps, err := r.stat()
+	if err != nil {
+		return ReloadResult{}, err
+	}
+	checksum, err := r.sourcesChecksum()
+	if err != nil {
+		return ReloadResult{}, err
+	}
+
+	ref, conflicts, err := loadReference(r.Paths, r.loadDataFunc, r.getUniqueKeyFunc, r.Policy)
+	if err != nil {
+		return ReloadResult{}, err
+	}
+
+	result := ReloadResult{
+		Version:    ref.version,
+		Snapshot:   ref.snapshot,
+		Sources:    r.Paths,
+		Checksum:   checksum,
+		Entries:    len(ref.cities),
+		Duplicates: len(conflicts),
+		LoadedAt:   time.Now(),
+	}
+
+	r.stamps, r.checksum, r.last = stamps, checksum, result
+
+	slog.Info("reloaded reference data", "version", result.Version, "sources", strings.Join(result.Sources, ","),
+		"snapshot", result.Snapshot, "entries", result.Entries, "duplicates", result.Duplicates)
+
+	return result, nil
+}
+
+func (r *ReferenceReloader) stat() (map[string]fileStamp, error) {
+	stamps := make(map[string]fileStamp, len(r.Paths))
+	for _, path := range r.Paths {
+		info, err := os.Stat(path)
+		if err != nil {
+			return nil, err
+		}
+		stamps[path] = fileStamp{modTime: info.ModTime(), size: info.Size()}
+	}
+	return stamps, nil
+}
+
+// sourcesChecksum hash over the checksums of every source, in layer order
+func (r *ReferenceReloader) sourcesChecksum() (string, error) {
+	h := sha256.New()
+	for _, path := range r.Paths {
+		sum, err := fileChecksum(path)
+		if err != nil {
+			return "", err
+		}
+		fmt.Fprintln(h, path, sum)
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// Watch polls the file every interval and reloads on SIGHUP until ctx is
+// done. An interval <= 0 disables polling.
+func (r *ReferenceReloader) Watch(ctx context.Context, interval time.Duration) {
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	defer signal.Stop(hup)
+
+	var tick <-chan time.Time
+	if interval > 0 {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		tick = ticker.C
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-hup:
+			if _, err := r.Reload(); err != nil {
+				slog.Error("reloading reference data", "err", err)
+			}
+		case <-tick:
+			if _, err := r.ReloadIfChanged(); err != nil {
+				slog.Error("reloading reference data", "err", err)
+			}
+		}
+	}
+}
+
+func fileChecksum(path string) (string, error) {
+	data, err := readData(path)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:]), nil
+}
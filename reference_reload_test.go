@@ -0,0 +1,164 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+func writeReferenceFile(t *testing.T, path string, cities []LocationData) {
+	t.Helper()
+	data, err := json.Marshal(cities)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestReferenceReloader(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "cities.json")
+	oslo := LocationData{Name: "Oslo", Country: "Norway", Geo: "59.57, 10.45", Latitude: "59.57", Longitude: "10.45"}
+	bergen := LocationData{Name: "Bergen", Country: "Norway", Geo: "60.23, 5.20", Latitude: "60.23", Longitude: "5.20"}
+	writeReferenceFile(t, path, []LocationData{oslo})
+
+	reloader := NewReferenceReloader(FirstWins, path)
+	first, err := reloader.Reload()
+	if err != nil {
+		t.Fatalf("Reload() error = %v", err)
+	}
+	if first.Entries != 1 || first.Checksum == "" || len(first.Sources) != 1 || first.Sources[0] != path {
+		t.Errorf("Reload() = %+v", first)
+	}
+
+	if changed, err := reloader.ReloadIfChanged(); err != nil || changed {
+		t.Errorf("ReloadIfChanged() on an untouched file = %v, %v", changed, err)
+	}
+
+	// same content with a new mtime is not a change
+	later := time.Now().Add(time.Minute)
+	if err := os.Chtimes(path, later, later); err != nil {
+		t.Fatal(err)
+	}
+	if changed, err := reloader.ReloadIfChanged(); err != nil || changed {
+		t.Errorf("ReloadIfChanged() after touch = %v, %v", changed, err)
+	}
+
+	writeReferenceFile(t, path, []LocationData{oslo, bergen})
+	if changed, err := reloader.ReloadIfChanged(); err != nil || !changed {
+		t.Fatalf("ReloadIfChanged() after edit = %v, %v", changed, err)
+	}
+	second := reloader.Last()
+	if second.Version != first.Version+1 || second.Entries != 2 || second.Checksum == first.Checksum || second.Snapshot == first.Snapshot {
+		t.Errorf("Last() = %+v after %+v", second, first)
+	}
+	if _, ok := loadedReference().cities[GetUniqueKey(bergen)]; !ok {
+		t.Errorf("new entry missing from the reference data")
+	}
+
+	// a broken file keeps the previous data
+	if err := os.WriteFile(path, []byte("not json"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := reloader.ReloadIfChanged(); err == nil {
+		t.Errorf("ReloadIfChanged() on invalid JSON expected an error")
+	}
+	if len(loadedReference().cities) != 2 || reloader.Last().Version != second.Version {
+		t.Errorf("failed reload replaced the registry")
+	}
+}
+
+func TestReferenceReloader_ConcurrentRuns(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "cities.json")
+	oslo := LocationData{Name: "Oslo", Country: "Norway", Geo: "59.57, 10.45", Latitude: "59.57", Longitude: "10.45"}
+	writeReferenceFile(t, path, []LocationData{oslo})
+	for i := 0; i < 5; i++ {
+		writeReferenceFile(t, filepath.Join(dir, "input-"+string(rune('a'+i))+".json"), []LocationData{oslo})
+	}
+
+	reloader := NewReferenceReloader(FirstWins, path)
+	if _, err := reloader.Reload(); err != nil {
+		t.Fatal(err)
+	}
+
+	helpers := HelperUtils{readData, parseCityFile, GetUniqueKey, hardCheck, getAllFiles}
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			// cities.json is an input file too, every record must stay valid
+			valid, invalid, _ := ProcessFiles(dir, helpers)
+			if len(invalid) != 0 || len(valid) != 6 {
+				t.Errorf("ProcessFiles() during reload = %d valid, %d invalid", len(valid), len(invalid))
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			if _, err := reloader.Reload(); err != nil {
+				t.Errorf("Reload() error = %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+func TestReferenceReloader_DoesNotWaitForRuns(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(t.TempDir(), "cities.json")
+	oslo := LocationData{Name: "Oslo", Country: "Norway", Geo: "59.57, 10.45", Latitude: "59.57", Longitude: "10.45"}
+	bergen := LocationData{Name: "Bergen", Country: "Norway", Geo: "60.23, 5.20", Latitude: "60.23", Longitude: "5.20"}
+	writeReferenceFile(t, path, []LocationData{oslo})
+	writeReferenceFile(t, filepath.Join(dir, "a.json"), []LocationData{oslo})
+	writeReferenceFile(t, filepath.Join(dir, "b.json"), []LocationData{oslo, bergen})
+
+	reloader := NewReferenceReloader(FirstWins, path)
+	if _, err := reloader.Reload(); err != nil {
+		t.Fatal(err)
+	}
+
+	// the run is stopped after its first file until the reload is done
+	reloaded := make(chan struct{})
+	var valid, invalid int
+	helpers := HelperUtils{readData, parseCityFile, GetUniqueKey, hardCheck, getAllFiles}
+	err := StreamFiles(context.Background(), dir, helpers, RunOptions{Ordered: true, Workers: 1}, func(event ResultEvent) error {
+		switch event.Kind {
+		case EventRecordValid:
+			valid++
+		case EventRecordInvalid:
+			invalid++
+		case EventFileDone:
+			if event.File == filepath.Join(dir, "a.json") {
+				go func() {
+					defer close(reloaded)
+					writeReferenceFile(t, path, []LocationData{oslo, bergen})
+					if _, err := reloader.Reload(); err != nil {
+						t.Errorf("Reload() error = %v", err)
+					}
+				}()
+				select {
+				case <-reloaded:
+				case <-time.After(5 * time.Second):
+					t.Fatal("Reload() waited for the run")
+				}
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// bergen is only in the reloaded data, the run keeps the data it started with
+	if valid != 2 || invalid != 1 {
+		t.Errorf("run = %d valid, %d invalid, want 2 and 1", valid, invalid)
+	}
+	if _, ok := loadedReference().cities[GetUniqueKey(bergen)]; !ok {
+		t.Errorf("reloaded entry missing from the reference data")
+	}
+}
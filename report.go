@@ -0,0 +1,229 @@
+package main
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+)
+
+// ReportFormat how the report of a validation run is written to stdout
+type ReportFormat string
+
+const (
+	TextReport ReportFormat = "text"
+	JSONReport ReportFormat = "json"
+	CSVReport  ReportFormat = "csv" // one row per record and unprocessable file
+)
+
+// ParseReportFormat reads the value of the -format flag
+func ParseReportFormat(s string) (ReportFormat, error) {
+	switch f := ReportFormat(s); f {
+	case TextReport, JSONReport, CSVReport:
+		return f, nil
+	}
+	return "", fmt.Errorf("unknown report format %q, want text, json or csv", s)
+}
+
+// SourceCount validated records that matched an entry of one reference source
+type SourceCount struct {
+	Path  string `json:"path"`
+	Count int    `json:"count"`
+}
+
+// RecordLocation file a record was read from and its index there
+type RecordLocation struct {
+	File  string `json:"file"`
+	Index int    `json:"index"`
+}
+
+// ValidResult record that passed validation
+type ValidResult struct {
+	Record   LocationData    `json:"record"`
+	Location *RecordLocation `json:"location,omitempty"` // unknown for the engines that only return records
+}
+
+// Report what a validation run found
+type Report struct {
+	ReferenceSnapshot string             `json:"reference_snapshot"`
+	Valid             []ValidResult      `json:"valid"`
+	Invalid           []InvalidResult    `json:"invalid"`
+	Unprocessable     []string           `json:"unprocessable"`
+	Matched           []SourceCount      `json:"matched"`
+	Cache             *CacheStats        `json:"cache,omitempty"`      // set with -cache-stats
+	Quarantine        []QuarantineReport `json:"quarantine,omitempty"` // files moved by -quarantine or -accepted
+}
+
+// NewReport report of a run against the loaded reference data, invalid
+// records get their best suggestion
+func NewReport(valid, invalid []LocationData, unprocessable []string) Report {
+	report := Report{Unprocessable: unprocessable}
+	for _, city := range valid {
+		report.Valid = append(report.Valid, ValidResult{Record: city})
+	}
+	for _, city := range invalid {
+		report.Invalid = append(report.Invalid, InvalidResult{Record: city})
+	}
+	return loadedReference().complete(report)
+}
+
+// addFile adds the records of one file, with where they came from, or the
+// file as unprocessable. The report is finished by complete.
+func (r *Report) addFile(result FileResult) {
+	if result.Err != nil {
+		r.Unprocessable = append(r.Unprocessable, result.Path)
+		return
+	}
+	for _, record := range result.Records {
+		location := &RecordLocation{File: result.Path, Index: record.Index}
+		if record.Valid {
+			r.Valid = append(r.Valid, ValidResult{Record: record.Record, Location: location})
+			continue
+		}
+		r.Invalid = append(r.Invalid, InvalidResult{Record: record.Record, Location: location})
+	}
+}
+
+// complete fills in what the reference data says about the records of
+// report: its snapshot, the matched sources and the suggestions
+func (ref *referenceData) complete(report Report) Report {
+	report.ReferenceSnapshot = ref.snapshot
+	report.Matched = ref.matchedSources(report.Valid)
+	for i := range report.Invalid {
+		if suggestion, ok := ref.suggest(report.Invalid[i].Record); ok {
+			report.Invalid[i].Suggestion = &suggestion
+		}
+	}
+	if report.Valid == nil {
+		report.Valid = []ValidResult{}
+	}
+	if report.Invalid == nil {
+		report.Invalid = []InvalidResult{}
+	}
+	if report.Unprocessable == nil {
+		report.Unprocessable = []string{}
+	}
+	return report
+}
+
+// matchedSources counts the validated records per reference source they matched, in layer order
+func (ref *referenceData) matchedSources(validated []ValidResult) []SourceCount {
+	counts := make(map[string]int)
+	for _, result := range validated {
+		counts[ref.source(GetUniqueKey(result.Record))]++
+	}
+
+	result := make([]SourceCount, 0, len(counts))
+	for path, count := range counts {
+		result = append(result, SourceCount{path, count})
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Path < result[j].Path })
+	return result
+}
+
+// WriteReport writes report to w in format
+func WriteReport(w io.Writer, format ReportFormat, report Report) error {
+	switch format {
+	case JSONReport:
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "    ")
+		return enc.Encode(report)
+	case CSVReport:
+		return writeCSVReport(w, report)
+	}
+	return writeTextReport(w, report)
+}
+
+func writeTextReport(w io.Writer, report Report) error {
+	var b bytes.Buffer
+	valid := make([]LocationData, len(report.Valid))
+	for i, result := range report.Valid {
+		valid[i] = result.Record
+	}
+	invalid := make([]LocationData, len(report.Invalid))
+	for i, result := range report.Invalid {
+		invalid[i] = result.Record
+	}
+
+	fmt.Fprintln(&b, "Successfully Validated Elements:", valid)
+	fmt.Fprintln(&b, "Unsuccessfully Validated Elements:", invalid)
+	fmt.Fprintln(&b, "Unprocessable Files:", report.Unprocessable)
+	for _, result := range report.Invalid {
+		if result.Suggestion != nil {
+			ref := result.Suggestion.Reference
+			fmt.Fprintf(&b, "Did you mean %s, %s (%s) from %s instead of %s, %s (%s)? confidence %.3f\n",
+				ref.Name, ref.Country, ref.Geo, result.Suggestion.Source, result.Record.Name, result.Record.Country, result.Record.Geo, result.Suggestion.Confidence)
+		}
+	}
+	fmt.Fprintln(&b, "Reference Snapshot:", report.ReferenceSnapshot)
+	for _, source := range report.Matched {
+		fmt.Fprintf(&b, "Matched against %s: %d\n", source.Path, source.Count)
+	}
+	fmt.Fprintln(&b, "Successfully Validated Elements:", len(report.Valid))
+	fmt.Fprintln(&b, "Unsuccessfully Validated Elements:", len(report.Invalid))
+	fmt.Fprintln(&b, "Unprocessable Files:", len(report.Unprocessable))
+	if stats := report.Cache; stats != nil {
+		fmt.Fprintf(&b, "Cache Hits: %d of %d files (%.1f%%)\n", stats.Hits, stats.Hits+stats.Misses, stats.HitRate()*100)
+	}
+	for _, moved := range report.Quarantine {
+		verb := "Moved"
+		if moved.Copied {
+			verb = "Copied"
+		}
+		fmt.Fprintf(&b, "%s %s to %s (%s)\n", verb, moved.File, moved.Destination, moved.Status)
+	}
+	_, err := w.Write(b.Bytes())
+	return err
+}
+
+// csvReportHeader columns of the CSV report, file and index say where a record
+// was read from, when the engine reports it, and the suggestion columns are
+// only filled for invalid records
+var csvReportHeader = []string{
+	"status", "file", "index", "city", "country", "geo", "latitude", "longitude", "province", "hemisphere",
+	"suggested_city", "suggested_country", "suggested_geo", "suggestion_source", "suggestion_confidence",
+}
+
+// writeCSVReport writes one row per record and unprocessable file. It holds
+// records only: the reference snapshot, matched sources, cache stats and
+// quarantine moves are in the text and JSON reports.
+func writeCSVReport(w io.Writer, report Report) error {
+	cw := csv.NewWriter(w)
+	if err := cw.Write(csvReportHeader); err != nil {
+		return err
+	}
+
+	recordRow := func(status string, c LocationData, location *RecordLocation) []string {
+		row := []string{status, "", "", c.Name, c.Country, c.Geo, c.Latitude, c.Longitude, c.Province, c.Hemisphere, "", "", "", "", ""}
+		if location != nil {
+			row[1], row[2] = location.File, strconv.Itoa(location.Index)
+		}
+		return row
+	}
+	for _, result := range report.Valid {
+		if err := cw.Write(recordRow("valid", result.Record, result.Location)); err != nil {
+			return err
+		}
+	}
+	for _, result := range report.Invalid {
+		row := recordRow("invalid", result.Record, result.Location)
+		if s := result.Suggestion; s != nil {
+			copy(row[10:], []string{s.Reference.Name, s.Reference.Country, s.Reference.Geo, s.Source, strconv.FormatFloat(s.Confidence, 'f', 3, 64)})
+		}
+		if err := cw.Write(row); err != nil {
+			return err
+		}
+	}
+	for _, file := range report.Unprocessable {
+		row := make([]string, len(csvReportHeader))
+		row[0], row[1] = "unprocessable", file
+		if err := cw.Write(row); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
@@ -0,0 +1,192 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// resultCacheVersion bumped whenever the cached result format changes, older indexes are discarded
+const resultCacheVersion = 1
+
+// cacheEntry cached outcome of one file content
+type cacheEntry struct {
+	Records []RecordResult `json:"records"`
+	Error   string         `json:"error,omitempty"`
+	Reason  string         `json:"reason,omitempty"` // classifyLoadError of Error
+}
+
+// cachedLoadError load error of a file restored from a cache entry
+type cachedLoadError struct {
+	message, reason string
+}
+
+func (e *cachedLoadError) Error() string { return e.message }
+
+// cacheIndex on-disk format of the cache
+type cacheIndex struct {
+	Version int                   `json:"version"`
+	Entries map[string]cacheEntry `json:"entries"`
+}
+
+// CacheStats lookups of a run
+type CacheStats struct {
+	Hits   int `json:"hits"`
+	Misses int `json:"misses"`
+}
+
+// HitRate share of lookups answered from the cache
+func (s CacheStats) HitRate() float64 {
+	if s.Hits+s.Misses == 0 {
+		return 0
+	}
+	return float64(s.Hits) / float64(s.Hits+s.Misses)
+}
+
+// ResultCache file results keyed by the content hash of the file, the reference
+// snapshot and the name normalization they were validated with. Save only
+// keeps the entries used since the cache was opened, so it does not outgrow
+// the folder it is used for.
+type ResultCache struct {
+	Path string
+
+	mu      sync.Mutex
+	entries map[string]cacheEntry
+	used    map[string]cacheEntry
+	stats   CacheStats
+}
+
+// OpenResultCache reads the index at path, a missing or outdated index gives an empty cache
+func OpenResultCache(path string) (*ResultCache, error) {
+	c := &ResultCache{Path: path, entries: map[string]cacheEntry{}, used: map[string]cacheEntry{}}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return c, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var index cacheIndex
+	if err := json.Unmarshal(data, &index); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	if index.Version == resultCacheVersion && index.Entries != nil {
+		c.entries = index.Entries
+	}
+	return c, nil
+}
+
+// Clear drops every entry, the next Save writes an empty index
+func (c *ResultCache) Clear() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.entries = map[string]cacheEntry{}
+	c.used = map[string]cacheEntry{}
+}
+
+// Stats hits and misses since the cache was opened
+func (c *ResultCache) Stats() CacheStats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.stats
+}
+
+// Save writes the entries used since the cache was opened, replacing the index atomically
+func (c *ResultCache) Save() error {
+	c.mu.Lock()
+	data, err := json.Marshal(cacheIndex{Version: resultCacheVersion, Entries: c.used})
+	c.mu.Unlock()
+	if err != nil {
+		return err
+	}
+
+	return writeFileAtomic(c.Path, data)
+}
+
+// writeFileAtomic writes data to a temporary file next to path and renames it
+// over path, so readers never see a partial file
+func writeFileAtomic(path string, data []byte) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name()) // no-op once renamed
+
+	if err := tmp.Chmod(0o644); err != nil {
+		tmp.Close()
+		return err
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// cacheKey key of a file content with checksum validated against the reference snapshot
+func cacheKey(checksum, snapshot string) string {
+	h := sha256.New()
+	fmt.Fprintln(h, checksum, snapshot, nameNormalization)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+func (e cacheEntry) result(path string) FileResult {
+	result := FileResult{Path: path, Records: e.Records}
+	if e.Error != "" {
+		result.Err = &cachedLoadError{message: e.Error, reason: e.Reason}
+	}
+	return result
+}
+
+func newCacheEntry(result FileResult) cacheEntry {
+	entry := cacheEntry{Records: result.Records}
+	if result.Err != nil {
+		entry.Error = result.Err.Error()
+		entry.Reason = classifyLoadError(result.Err)
+	}
+	return entry
+}
+
+// lookup cached result for the file content with checksum validated against
+// the reference snapshot, a nil cache never hits
+func (c *ResultCache) lookup(path, checksum, snapshot string) (FileResult, bool) {
+	if c == nil {
+		return FileResult{}, false
+	}
+	key := cacheKey(checksum, snapshot)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	entry, ok := c.entries[key]
+	if !ok {
+		c.stats.Misses++
+		return FileResult{}, false
+	}
+	c.stats.Hits++
+	c.used[key] = entry
+	return entry.result(path), true
+}
+
+// store caches result for the file content with checksum validated against the reference snapshot
+func (c *ResultCache) store(checksum, snapshot string, result FileResult) {
+	if c == nil {
+		return
+	}
+	key := cacheKey(checksum, snapshot)
+	entry := newCacheEntry(result)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.entries[key] = entry
+	c.used[key] = entry
+}
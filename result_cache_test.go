@@ -0,0 +1,170 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestResultCache(t *testing.T) {
+	useReference(map[Key]LocationData{GetUniqueKey(oslo): oslo}, "v1")
+	defer func() { nameNormalization = DefaultNormalization }()
+
+	dir := t.TempDir()
+	writeReferenceFile(t, filepath.Join(dir, "city-1.json"), []LocationData{oslo, rabat})
+	if err := os.WriteFile(filepath.Join(dir, "city-2.json"), []byte("[{"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	cachePath := filepath.Join(t.TempDir(), "cache.json")
+	helpers := HelperUtils{readData, parseCityFile, GetUniqueKey, hardCheck, getAllFiles}
+
+	tests := []struct {
+		name       string
+		change     func()
+		clear      bool
+		wantHits   int
+		wantMisses int
+	}{
+		{"cold cache", func() {}, false, 0, 2},
+		{"warm cache", func() {}, false, 2, 0},
+		{"changed file", func() { writeReferenceFile(t, filepath.Join(dir, "city-1.json"), []LocationData{rabat}) }, false, 1, 1},
+		{"new reference snapshot", func() { useReference(map[Key]LocationData{GetUniqueKey(oslo): oslo}, "v2") }, false, 0, 2},
+		{"other normalization", func() { nameNormalization = NormalizeOptions{Form: FormNFC, CaseFold: true} }, false, 0, 2},
+		{"cleared", func() {}, true, 0, 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.change()
+			cache, err := OpenResultCache(cachePath)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tt.clear {
+				cache.Clear()
+			}
+
+			results, err := ProcessFileResultsWith(dir, helpers, RunOptions{Ordered: true, Cache: cache})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := cache.Save(); err != nil {
+				t.Fatal(err)
+			}
+
+			if stats := cache.Stats(); stats.Hits != tt.wantHits || stats.Misses != tt.wantMisses {
+				t.Errorf("Stats() = %+v, want %d hits and %d misses", stats, tt.wantHits, tt.wantMisses)
+			}
+			uncached, err := ProcessFileResults(dir, helpers)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for i := range results {
+				if results[i].Path != uncached[i].Path || (results[i].Err == nil) != (uncached[i].Err == nil) ||
+					len(results[i].Records) != len(uncached[i].Records) || len(results[i].Invalid()) != len(uncached[i].Invalid()) {
+					t.Errorf("cached result %+v, want %+v", results[i], uncached[i])
+				}
+			}
+		})
+	}
+}
+
+func TestResultCacheSaveKeepsUsedEntries(t *testing.T) {
+	useReference(map[Key]LocationData{GetUniqueKey(oslo): oslo}, "")
+	cachePath := filepath.Join(t.TempDir(), "cache.json")
+	helpers := HelperUtils{readData, parseCityFile, GetUniqueKey, hardCheck, getAllFiles}
+
+	first, second := t.TempDir(), t.TempDir()
+	writeReferenceFile(t, filepath.Join(first, "city-1.json"), []LocationData{oslo})
+	writeReferenceFile(t, filepath.Join(second, "city-1.json"), []LocationData{rabat})
+
+	for _, dir := range []string{first, second} {
+		cache, err := OpenResultCache(cachePath)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := ProcessFileResultsWith(dir, helpers, RunOptions{Ordered: true, Cache: cache}); err != nil {
+			t.Fatal(err)
+		}
+		if err := cache.Save(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	cache, err := OpenResultCache(cachePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(cache.entries) != 1 {
+		t.Errorf("cache holds %d entries, want only the one used by the last run", len(cache.entries))
+	}
+}
+
+// TestDuplicatePolicySwitch reruns the same files after the duplicate policy
+// changed, nothing validated under the old policy may be reused
+func TestDuplicatePolicySwitch(t *testing.T) {
+	renamed := oslo
+	renamed.Province = "Viken"
+	reference := filepath.Join(t.TempDir(), "cities.json")
+	writeReferenceFile(t, reference, []LocationData{oslo, renamed})
+	dir := t.TempDir()
+	writeReferenceFile(t, filepath.Join(dir, "city-1.json"), []LocationData{renamed})
+
+	cachePath := filepath.Join(t.TempDir(), "cache.json")
+	checkpointPath := filepath.Join(t.TempDir(), "checkpoint.json")
+	helpers := HelperUtils{readData, parseCityFile, GetUniqueKey, hardCheck, getAllFiles}
+	watcher, err := NewDirWatcher(dir, filepath.Join(t.TempDir(), "results.jsonl"), helpers)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := watcher.Poll(); err != nil { // first sighting, not stable yet
+		t.Fatal(err)
+	}
+
+	for i, tt := range []struct {
+		policy    DuplicatePolicy
+		wantValid bool
+	}{{FirstWins, false}, {LastWins, true}, {FirstWins, false}} {
+		if _, err := loadAuthenticCities(reference, loadDataToStruct, GetUniqueKey, tt.policy); err != nil {
+			t.Fatal(err)
+		}
+
+		cache, err := OpenResultCache(cachePath)
+		if err != nil {
+			t.Fatal(err)
+		}
+		results, err := ProcessFileResultsWith(dir, helpers, RunOptions{Ordered: true, Cache: cache})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := cache.Save(); err != nil {
+			t.Fatal(err)
+		}
+		if got := results[0].Records[0].Valid; got != tt.wantValid {
+			t.Errorf("run %d with %s: cached run valid = %v, want %v", i, tt.policy, got, tt.wantValid)
+		}
+
+		// the checkpoint of the previous run was left behind, as after a kill
+		checkpoint, err := NewCheckpointer(checkpointPath, 0, i > 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		results, err = ProcessFileResultsWith(dir, helpers, RunOptions{Ordered: true, Checkpoint: checkpoint})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := results[0].Records[0].Valid; got != tt.wantValid {
+			t.Errorf("run %d with %s: resumed run valid = %v, want %v", i, tt.policy, got, tt.wantValid)
+		}
+		if i > 0 && !checkpoint.Stale() {
+			t.Errorf("run %d with %s: checkpoint of the other policy was resumed", i, tt.policy)
+		}
+
+		events, err := watcher.Poll()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(events) != 1 || (len(events[0].Invalid) == 0) != tt.wantValid {
+			t.Errorf("run %d with %s: watcher events = %+v, want the file validated again", i, tt.policy, events)
+		}
+	}
+}
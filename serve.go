@@ -0,0 +1,253 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"log/slog"
+	"math"
+	"net/http"
+	"net/url"
+	"os"
+	"os/signal"
+	"strconv"
+	"syscall"
+	"time"
+)
+
+// ValidateResponse body returned by POST /validate
+type ValidateResponse struct {
+	ReferenceVersion  int             `json:"reference_version"`
+	ReferenceSnapshot string          `json:"reference_snapshot"`
+	Valid             []LocationData  `json:"valid"`
+	Invalid           []InvalidResult `json:"invalid"`
+}
+
+// NearbyResponse body returned by GET /nearby, nearest first
+type NearbyResponse struct {
+	ReferenceVersion  int        `json:"reference_version"`
+	ReferenceSnapshot string     `json:"reference_snapshot"`
+	Neighbors         []Neighbor `json:"neighbors"`
+}
+
+// maxNearby most neighbors GET /nearby returns for n
+const maxNearby = 1000
+
+// nearbyQuery the parameters of GET /nearby: lat and lon, and either the
+// number of neighbors n or a radius r in kilometres
+type nearbyQuery struct {
+	point    GeoPoint
+	n        int
+	radiusKm float64
+}
+
+func parseNearbyQuery(values url.Values) (nearbyQuery, error) {
+	var q nearbyQuery
+	var err error
+	if q.point.Lat, err = parseQueryFloat(values, "lat"); err != nil {
+		return q, err
+	}
+	if q.point.Lon, err = parseQueryFloat(values, "lon"); err != nil {
+		return q, err
+	}
+	if q.point.Lat < -90 || q.point.Lat > 90 || q.point.Lon < -180 || q.point.Lon > 180 {
+		return q, fmt.Errorf("lat %v, lon %v out of range", q.point.Lat, q.point.Lon)
+	}
+
+	switch n, r := values.Get("n"), values.Get("r"); {
+	case n != "" && r != "":
+		return q, errors.New("pass either n or r, not both")
+	case n != "":
+		if q.n, err = strconv.Atoi(n); err != nil || q.n <= 0 || q.n > maxNearby {
+			return q, fmt.Errorf("n %q is not a number from 1 to %d", n, maxNearby)
+		}
+	case r != "":
+		if q.radiusKm, err = parseQueryFloat(values, "r"); err != nil || q.radiusKm < 0 {
+			return q, fmt.Errorf("r %q is not a distance in km", r)
+		}
+	default:
+		return q, errors.New("missing n or r")
+	}
+	return q, nil
+}
+
+// parseQueryFloat the finite number in query parameter name
+func parseQueryFloat(values url.Values, name string) (float64, error) {
+	v, err := strconv.ParseFloat(values.Get(name), 64)
+	if err != nil || math.IsNaN(v) || math.IsInf(v, 0) {
+		return 0, fmt.Errorf("%s %q is not a number", name, values.Get(name))
+	}
+	return v, nil
+}
+
+// maxValidateBody largest body POST /validate reads
+const maxValidateBody = 10 << 20
+
+// newServeMux public routes of the long-running service
+func newServeMux(helpers HelperUtils) *http.ServeMux {
+	mux := http.NewServeMux()
+
+	mux.HandleFunc("POST /validate", func(w http.ResponseWriter, req *http.Request) {
+		var cities []LocationData
+		if err := json.NewDecoder(http.MaxBytesReader(w, req.Body, maxValidateBody)).Decode(&cities); err != nil {
+			var tooLarge *http.MaxBytesError
+			if errors.As(err, &tooLarge) {
+				http.Error(w, fmt.Sprintf("body larger than %d bytes", tooLarge.Limit), http.StatusRequestEntityTooLarge)
+				return
+			}
+			http.Error(w, "invalid JSON body: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		ref := loadedReference()
+		resp := ValidateResponse{ReferenceVersion: ref.version, ReferenceSnapshot: ref.snapshot, Valid: []LocationData{}}
+		var invalid []LocationData
+		for _, element := range cities {
+			verifyData, ok := ref.lookup(helpers.getUniqueKeyFunc(element))
+			valid := ok && helpers.hardValidateFunc(verifyData, element)
+			metrics.observeRecord(ref.countryLabel(element.Country), ok, valid)
+			if valid {
+				resp.Valid = append(resp.Valid, element)
+			} else {
+				invalid = append(invalid, element)
+			}
+		}
+		resp.Invalid = ref.attachSuggestions(invalid)
+
+		writeJSON(w, http.StatusOK, resp)
+	})
+
+	mux.HandleFunc("GET /nearby", func(w http.ResponseWriter, req *http.Request) {
+		q, err := parseNearbyQuery(req.URL.Query())
+		if err != nil {
+			http.Error(w, "invalid query: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		ref := loadedReference()
+		resp := NearbyResponse{ReferenceVersion: ref.version, ReferenceSnapshot: ref.snapshot}
+		if q.n > 0 {
+			resp.Neighbors = ref.index.Nearest(q.point, q.n)
+		} else {
+			resp.Neighbors = ref.index.Within(q.point, q.radiusKm)
+		}
+		if resp.Neighbors == nil {
+			resp.Neighbors = []Neighbor{}
+		}
+		writeJSON(w, http.StatusOK, resp)
+	})
+
+	mux.Handle("GET /metrics", metrics.registry)
+	return mux
+}
+
+// newAdminMux routes that show and reload the reference data, served on -admin-addr only
+func newAdminMux(reloader *ReferenceReloader) *http.ServeMux {
+	mux := http.NewServeMux()
+
+	mux.HandleFunc("GET /admin/reference", func(w http.ResponseWriter, req *http.Request) {
+		writeJSON(w, http.StatusOK, reloader.Last())
+	})
+
+	mux.HandleFunc("POST /admin/reload", func(w http.ResponseWriter, req *http.Request) {
+		result, err := reloader.Reload()
+		if err != nil {
+			http.Error(w, "reload failed: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+		writeJSON(w, http.StatusOK, result)
+	})
+
+	return mux
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+// runServe handles `serve [flags]`
+func runServe(args []string) int {
+	fs := flag.NewFlagSet("serve", flag.ExitOnError)
+	applyNormalization := addNormalizationFlags(fs)
+	applyLogging := addLogFlags(fs)
+	addr := fs.String("addr", ":8080", "listen address")
+	reference := addReferenceFlags(fs)
+	poll := fs.Duration("poll", 30*time.Second, "how often to check the reference files for changes, 0 disables polling")
+	profiling := fs.Bool("pprof", false, "serve the net/http/pprof profiles under /debug/pprof/ on -pprof-addr")
+	pprofAddr := fs.String("pprof-addr", "localhost:6060", "listen address of the pprof profiles, kept apart from -addr")
+	adminAddr := fs.String("admin-addr", "localhost:8081", "listen address of /admin/reference and /admin/reload, kept apart from -addr, empty disables them")
+	_ = fs.Parse(args)
+
+	if err := applyLogging(); err != nil {
+		slog.Error("parsing flags", "err", err)
+		return 2
+	}
+	if err := applyNormalization(); err != nil {
+		slog.Error("parsing flags", "err", err)
+		return 2
+	}
+	policy, err := reference.policy()
+	if err != nil {
+		slog.Error("parsing flags", "err", err)
+		return 2
+	}
+
+	reloader := NewReferenceReloader(policy, reference.sources()...)
+	if _, err := reloader.Reload(); err != nil {
+		slog.Error("loading authentic cities", "err", err)
+		return 1
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	go reloader.Watch(ctx, *poll)
+
+	if *profiling {
+		// the profiles expose internals, they never share the public listener
+		pprofMux := http.NewServeMux()
+		registerPprof(pprofMux)
+		pprofServer := &http.Server{Addr: *pprofAddr, Handler: pprofMux, ReadHeaderTimeout: 10 * time.Second}
+		go func() {
+			if err := pprofServer.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+				slog.Error("serving pprof", "addr", *pprofAddr, "err", err)
+			}
+		}()
+		defer pprofServer.Close()
+		slog.Info("serving pprof", "addr", *pprofAddr)
+	}
+
+	if *adminAddr != "" {
+		// anyone who reaches the admin routes can reload the reference data
+		adminServer := &http.Server{Addr: *adminAddr, Handler: newAdminMux(reloader), ReadHeaderTimeout: 10 * time.Second}
+		go func() {
+			if err := adminServer.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+				slog.Error("serving admin endpoints", "addr", *adminAddr, "err", err)
+			}
+		}()
+		defer adminServer.Close()
+		slog.Info("serving admin endpoints", "addr", *adminAddr)
+	}
+
+	server := &http.Server{
+		Addr:              *addr,
+		Handler:           newServeMux(HelperUtils{readData, parseCityFile, GetUniqueKey, hardCheck, getAllFiles}),
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		_ = server.Shutdown(shutdownCtx)
+	}()
+
+	slog.Info("listening", "addr", *addr)
+	if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+		slog.Error("serving", "err", err)
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,191 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestServeMux(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "cities.json")
+	oslo := LocationData{Name: "Oslo", Country: "Norway", Geo: "59.57, 10.45", Latitude: "59.57", Longitude: "10.45"}
+	writeReferenceFile(t, path, []LocationData{oslo})
+
+	reloader := NewReferenceReloader(FirstWins, path)
+	if _, err := reloader.Reload(); err != nil {
+		t.Fatal(err)
+	}
+	server := httptest.NewServer(newServeMux(HelperUtils{readData, parseCityFile, GetUniqueKey, hardCheck, getAllFiles}))
+	defer server.Close()
+	admin := httptest.NewServer(newAdminMux(reloader))
+	defer admin.Close()
+
+	body := `[{"City":"Oslo","country":"Norway","geo":"59.57, 10.45","latitude":"59.57","longitude":"10.45"},
+	          {"City":"Olso","country":"Norway","geo":"59.57, 10.45","latitude":"59.57","longitude":"10.45"},
+	          {"City":"Poseidonis","country":"Atlantis-7f3a","geo":"0, 0","latitude":"0","longitude":"0"}]`
+	resp, err := http.Post(server.URL+"/validate", "application/json", strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	var got ValidateResponse
+	if err := json.NewDecoder(resp.Body).Decode(&got); err != nil {
+		t.Fatal(err)
+	}
+	if len(got.Valid) != 1 || len(got.Invalid) != 2 {
+		t.Fatalf("POST /validate = %+v", got)
+	}
+	if s := got.Invalid[0].Suggestion; s == nil || s.Reference.Name != "Oslo" {
+		t.Errorf("POST /validate suggestion = %+v, want Oslo", s)
+	}
+
+	resp, err = http.Post(server.URL+"/validate", "application/json", strings.NewReader("{"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("POST /validate with bad JSON status = %d", resp.StatusCode)
+	}
+
+	resp, err = http.Post(server.URL+"/validate", "application/json", strings.NewReader("["+strings.Repeat(" ", maxValidateBody)+"]"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusRequestEntityTooLarge {
+		t.Errorf("POST /validate with a too large body status = %d, want 413", resp.StatusCode)
+	}
+
+	// the admin routes are only served on their own listener
+	resp, err = http.Post(server.URL+"/admin/reload", "", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNotFound {
+		t.Errorf("POST /admin/reload on the public listener = %d, want 404", resp.StatusCode)
+	}
+
+	resp, err = http.Post(admin.URL+"/admin/reload", "", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var reloaded ReloadResult
+	_ = json.NewDecoder(resp.Body).Decode(&reloaded)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || reloaded.Version != got.ReferenceVersion+1 {
+		t.Errorf("POST /admin/reload = %d %+v", resp.StatusCode, reloaded)
+	}
+
+	resp, err = http.Get(admin.URL + "/admin/reference")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var status ReloadResult
+	_ = json.NewDecoder(resp.Body).Decode(&status)
+	resp.Body.Close()
+	if status.Version != reloaded.Version || status.Entries != 1 {
+		t.Errorf("GET /admin/reference = %+v", status)
+	}
+
+	resp, err = http.Get(server.URL + "/metrics")
+	if err != nil {
+		t.Fatal(err)
+	}
+	exposition, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	for _, want := range []string{
+		"cities_reference_entries 1\n",
+		`cities_records_valid_total{country="Norway"}`,
+		`cities_records_invalid_total{country="Norway",reason="unknown_key"}`,
+		`cities_records_invalid_total{country="unknown",reason="unknown_key"}`,
+	} {
+		if !strings.Contains(string(exposition), want) {
+			t.Errorf("GET /metrics = %s, want it to contain %q", exposition, want)
+		}
+	}
+	// countries outside the reference data never become a label
+	if strings.Contains(string(exposition), "Atlantis-7f3a") {
+		t.Errorf("GET /metrics = %s, has a series for a country sent by the client", exposition)
+	}
+
+	// pprof is only served on its own listener
+	resp, err = http.Get(server.URL + "/debug/pprof/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNotFound {
+		t.Errorf("GET /debug/pprof/ on the public listener = %d, want 404", resp.StatusCode)
+	}
+}
+
+func TestServeNearby(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "cities.json")
+	oslo := LocationData{Name: "Oslo", Country: "Norway", Geo: "59.57, 10.45", Latitude: "59.57", Longitude: "10.45", Hemisphere: "NE"}
+	bergen := LocationData{Name: "Bergen", Country: "Norway", Geo: "60.23, 5.20", Latitude: "60.23", Longitude: "5.20", Hemisphere: "NE"}
+	rabat := LocationData{Name: "Rabat", Country: "Morocco", Geo: "34.02, 6.50", Latitude: "34.02", Longitude: "6.50", Hemisphere: "NW"}
+	writeReferenceFile(t, path, []LocationData{oslo, bergen, rabat})
+
+	reloader := NewReferenceReloader(FirstWins, path)
+	if _, err := reloader.Reload(); err != nil {
+		t.Fatal(err)
+	}
+	server := httptest.NewServer(newServeMux(HelperUtils{readData, parseCityFile, GetUniqueKey, hardCheck, getAllFiles}))
+	defer server.Close()
+
+	tests := []struct {
+		query      string
+		wantStatus int
+		want       []string
+	}{
+		{"lat=59.9&lon=10.7&n=2", http.StatusOK, []string{"Oslo", "Bergen"}},
+		{"lat=59.9&lon=10.7&n=10", http.StatusOK, []string{"Oslo", "Bergen", "Rabat"}},
+		{"lat=59.9&lon=10.7&r=400", http.StatusOK, []string{"Oslo", "Bergen"}},
+		{"lat=59.9&lon=10.7&r=2", http.StatusOK, []string{}},
+		{"lat=34&lon=-6.8&r=0", http.StatusOK, []string{}},
+		{"lat=59.9&lon=10.7", http.StatusBadRequest, nil},
+		{"lat=59.9&lon=10.7&n=2&r=400", http.StatusBadRequest, nil},
+		{"lat=59.9&lon=10.7&n=0", http.StatusBadRequest, nil},
+		{"lat=59.9&lon=10.7&r=-1", http.StatusBadRequest, nil},
+		{"lat=91&lon=10.7&n=1", http.StatusBadRequest, nil},
+		{"lat=NaN&lon=10.7&n=1", http.StatusBadRequest, nil},
+		{"lon=10.7&n=1", http.StatusBadRequest, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			resp, err := http.Get(server.URL + "/nearby?" + tt.query)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer resp.Body.Close()
+			if resp.StatusCode != tt.wantStatus {
+				t.Fatalf("GET /nearby status = %d, want %d", resp.StatusCode, tt.wantStatus)
+			}
+			if tt.want == nil {
+				return
+			}
+
+			var got NearbyResponse
+			if err := json.NewDecoder(resp.Body).Decode(&got); err != nil {
+				t.Fatal(err)
+			}
+			names := []string{}
+			for _, neighbor := range got.Neighbors {
+				names = append(names, neighbor.City.Name)
+			}
+			if strings.Join(names, ",") != strings.Join(tt.want, ",") {
+				t.Errorf("GET /nearby = %v, want %v", names, tt.want)
+			}
+			if got.ReferenceSnapshot != reloader.Last().Snapshot {
+				t.Errorf("GET /nearby snapshot = %q, want %q", got.ReferenceSnapshot, reloader.Last().Snapshot)
+			}
+		})
+	}
+}
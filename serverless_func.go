@@ -3,13 +3,77 @@ package main
 import (
 	"encoding/json"
 	"fmt"
+	"log/slog"
 	"os"
 	"path/filepath"
+	"slices"
+	"strings"
 	"sync"
+	"sync/atomic"
 )
 
-// authenticCities Cache to check and validate the input data
-var authenticCities map[Key]LocationData
+// referenceData one loaded version of the reference data. It is never
+// changed once published, a reload publishes a new one.
+type referenceData struct {
+	cities    map[Key]LocationData // Cache to check and validate the input data
+	sources   map[Key]string       // source file of every entry in cities
+	index     *SpatialIndex        // spatial index over cities
+	countries map[string]string    // normalized country name to its spelling in cities
+	version   int                  // number of reference loads so far
+	snapshot  string               // content hash, see registrySnapshot
+}
+
+// currentReference reference data of the runs starting now. A run loads it
+// once and keeps using what it got, so a reload never waits for a run and
+// never changes the data under one.
+var currentReference atomic.Pointer[referenceData]
+
+// publishMu serializes publishReference so every load gets its own version
+var publishMu sync.Mutex
+
+// loadedReference current reference data, empty before the first load
+func loadedReference() *referenceData {
+	if ref := currentReference.Load(); ref != nil {
+		return ref
+	}
+	return &referenceData{}
+}
+
+// publishReference numbers ref and makes it the reference data of later runs
+func publishReference(ref *referenceData) *referenceData {
+	publishMu.Lock()
+	defer publishMu.Unlock()
+	ref.version = loadedReference().version + 1
+	ref.countries = make(map[string]string)
+	for _, city := range ref.cities {
+		if _, ok := ref.countries[nameNormalization.Apply(city.Country)]; !ok {
+			ref.countries[nameNormalization.Apply(city.Country)] = city.Country
+		}
+	}
+	currentReference.Store(ref)
+	return ref
+}
+
+// lookup reference entry for key
+func (ref *referenceData) lookup(key Key) (LocationData, bool) {
+	city, ok := ref.cities[key]
+	return city, ok
+}
+
+// countryLabel metrics label for country: its spelling in the reference
+// data, or "unknown" for countries the reference data does not have, so
+// clients cannot add a series per made up country
+func (ref *referenceData) countryLabel(country string) string {
+	if label, ok := ref.countries[nameNormalization.Apply(country)]; ok {
+		return label
+	}
+	return "unknown"
+}
+
+// source path of the source the reference entry for key came from
+func (ref *referenceData) source(key Key) string {
+	return ref.sources[key]
+}
 
 // readData reads the file and returns the contents.
 // A successful call returns err == nil, not err == EOF.
@@ -22,9 +86,13 @@ func loadDataToStruct(filepath string) ([]LocationData, error) {
 	if err != nil {
 		return nil, err
 	}
+	return parseCities(data)
+}
 
+// parseCities decodes the JSON array of a city file
+func parseCities(data []byte) ([]LocationData, error) {
 	var cities []LocationData
-	err = json.Unmarshal(data, &cities)
+	err := json.Unmarshal(data, &cities)
 	if err != nil {
 		return nil, err
 	}
@@ -32,29 +100,100 @@ func loadDataToStruct(filepath string) ([]LocationData, error) {
 	return cities, nil
 }
 
+// parseCityFile decodes the content of the city file at path, the parseFunc of HelperUtils
+func parseCityFile(_ string, data []byte) ([]LocationData, error) {
+	return parseCities(data)
+}
+
+// loadAuthenticCities loads and publishes the reference data and
+// returns every duplicate Key it came across, resolved according to policy.
+// With FailOnDuplicate the current reference data is left untouched.
 func loadAuthenticCities(
 	filepath string,
 	loadDataFunc func(string) ([]LocationData, error),
 	getUniqueKeyFunc func(data LocationData) Key,
-) error {
-	cities, err := loadDataFunc(filepath)
-	if err != nil {
-		return err
-	}
+	policy DuplicatePolicy,
+) ([]DuplicateConflict, error) {
+	return loadReferenceLayers([]string{filepath}, loadDataFunc, getUniqueKeyFunc, policy)
+}
 
-	authenticCities = make(map[Key]LocationData, len(cities))
+// loadReferenceLayers loads an ordered list of reference sources into one
+// registry. Duplicates inside a source are resolved with policy, an entry in
+// a later source replaces the one from earlier sources with the same Key.
+// The published data remembers which source every entry came from.
+func loadReferenceLayers(
+	paths []string,
+	loadDataFunc func(string) ([]LocationData, error),
+	getUniqueKeyFunc func(data LocationData) Key,
+	policy DuplicatePolicy,
+) ([]DuplicateConflict, error) {
+	_, conflicts, err := loadReference(paths, loadDataFunc, getUniqueKeyFunc, policy)
+	return conflicts, err
+}
 
-	for _, city := range cities {
-		key := getUniqueKeyFunc(city)
+// loadReference loadReferenceLayers returning the reference data it published
+func loadReference(
+	paths []string,
+	loadDataFunc func(string) ([]LocationData, error),
+	getUniqueKeyFunc func(data LocationData) Key,
+	policy DuplicatePolicy,
+) (*referenceData, []DuplicateConflict, error) {
+	registry := make(map[Key]LocationData)
+	sources := make(map[Key]string)
+	var order []Key
+	var conflicts []DuplicateConflict
+
+	for _, path := range paths {
+		cities, err := loadDataFunc(path)
+		if err != nil {
+			if len(paths) > 1 {
+				return nil, nil, fmt.Errorf("%s: %w", path, err)
+			}
+			return nil, nil, err
+		}
 
-		if _, ok := authenticCities[key]; ok {
-			fmt.Printf("Got a duplicate with details %+v \n", city)
-		} else {
-			authenticCities[key] = city
+		layer := make(map[Key]LocationData, len(cities))
+		for i, city := range cities {
+			key := getUniqueKeyFunc(city)
+
+			existing, ok := layer[key]
+			if !ok {
+				layer[key] = city
+				if _, seen := registry[key]; !seen {
+					order = append(order, key)
+				}
+				registry[key], sources[key] = city, path
+				continue
+			}
+
+			kept := policy.resolve(existing, city)
+			layer[key], registry[key] = kept, kept
+			conflicts = append(conflicts, DuplicateConflict{
+				Key: key, Source: path, Index: i, Existing: existing, Duplicate: city, Kept: kept, Policy: policy,
+			})
 		}
 	}
 
-	return nil
+	if policy == FailOnDuplicate && len(conflicts) > 0 {
+		return nil, conflicts, fmt.Errorf("%w: %d duplicate keys in %s", ErrDuplicateReference, len(conflicts), strings.Join(paths, ", "))
+	}
+
+	unique := make([]LocationData, 0, len(order))
+	var origins []string // only tell the layers apart when there are several
+	for _, key := range order {
+		unique = append(unique, registry[key])
+		if len(paths) > 1 {
+			origins = append(origins, sources[key])
+		}
+	}
+
+	ref := publishReference(&referenceData{
+		cities:   registry,
+		sources:  sources,
+		index:    NewSpatialIndex(unique),
+		snapshot: registrySnapshot(unique, origins),
+	})
+	return ref, conflicts, nil
 }
 
 func getAllFiles(tmpFolder string) ([]string, error) {
@@ -73,35 +212,80 @@ func getAllFiles(tmpFolder string) ([]string, error) {
 }
 
 type HelperUtils struct {
-	loadDataFunc     func(string) ([]LocationData, error)
+	readFunc         func(string) ([]byte, error)
+	parseFunc        func(path string, data []byte) ([]LocationData, error)
 	getUniqueKeyFunc func(data LocationData) Key
 	hardValidateFunc func(LocationData, LocationData) bool
 	getAllFiles      func(string) ([]string, error)
 }
 
+// loadDataFunc reads the file at path with readFunc and decodes it with parseFunc
+func (h HelperUtils) loadDataFunc(path string) ([]LocationData, error) {
+	data, err := h.readFunc(path)
+	if err != nil {
+		return nil, err
+	}
+	return h.parseFunc(path, data)
+}
+
 func ProcessFiles(
 	tmpFolder string,
 	helpers HelperUtils,
+) ([]LocationData, []LocationData, []string) {
+	return ProcessFilesWith(tmpFolder, helpers, false)
+}
+
+// ProcessFilesWith is ProcessFiles, with ordered the records come back ordered
+// by file path and record index instead of in the order the goroutines finished.
+// Files are validated concurrently either way.
+func ProcessFilesWith(
+	tmpFolder string,
+	helpers HelperUtils,
+	ordered bool,
 ) ([]LocationData, []LocationData, []string) {
 	var successfullyValidated, unsuccessfullyValidated []LocationData
 	var unprocessableFiles []string
 
+	ref := loadedReference()
 	allFiles, err := helpers.getAllFiles(tmpFolder)
 	if err != nil {
-		fmt.Println("Error reading tmp folder:", err)
+		slog.Error("reading tmp folder", "dir", tmpFolder, "err", err)
 		return nil, nil, nil
 	}
 
 	var wg sync.WaitGroup
 	var mu sync.Mutex
 
-	for _, fileP := range allFiles {
-		wg.Add(1)
-		go processFile(fileP, &wg, &mu, &unprocessableFiles, &successfullyValidated, &unsuccessfullyValidated, helpers)
+	if !ordered {
+		for _, fileP := range allFiles {
+			wg.Add(1)
+			go processFileAgainst(ref, fileP, &wg, &mu, &unprocessableFiles, &successfullyValidated, &unsuccessfullyValidated, helpers)
+		}
+		wg.Wait()
+		return successfullyValidated, unsuccessfullyValidated, unprocessableFiles
 	}
 
+	// every file collects into its own slot, joined in path order at the end
+	allFiles = slices.Clone(allFiles)
+	slices.Sort(allFiles)
+	type fileRecords struct {
+		mu             sync.Mutex
+		unprocessable  []string
+		valid, invalid []LocationData
+	}
+	perFile := make([]fileRecords, len(allFiles))
+	for i, fileP := range allFiles {
+		wg.Add(1)
+		slot := &perFile[i]
+		go processFileAgainst(ref, fileP, &wg, &slot.mu, &slot.unprocessable, &slot.valid, &slot.invalid, helpers)
+	}
 	wg.Wait()
 
+	for i := range perFile {
+		unprocessableFiles = append(unprocessableFiles, perFile[i].unprocessable...)
+		successfullyValidated = append(successfullyValidated, perFile[i].valid...)
+		unsuccessfullyValidated = append(unsuccessfullyValidated, perFile[i].invalid...)
+	}
 	return successfullyValidated, unsuccessfullyValidated, unprocessableFiles
 }
 
@@ -113,6 +297,20 @@ func processFile(
 	successfullyValidated *[]LocationData,
 	unsuccessfullyValidated *[]LocationData,
 	helper HelperUtils,
+) {
+	processFileAgainst(loadedReference(), tmpPath, wg, mu, unprocessableFiles, successfullyValidated, unsuccessfullyValidated, helper)
+}
+
+// processFileAgainst processFile validating against ref
+func processFileAgainst(
+	ref *referenceData,
+	tmpPath string,
+	wg *sync.WaitGroup,
+	mu *sync.Mutex,
+	unprocessableFiles *[]string,
+	successfullyValidated *[]LocationData,
+	unsuccessfullyValidated *[]LocationData,
+	helper HelperUtils,
 ) {
 	defer wg.Done()
 
@@ -125,7 +323,7 @@ func processFile(
 	}
 
 	for _, element := range cities {
-		verifyData, ok := authenticCities[helper.getUniqueKeyFunc(element)]
+		verifyData, ok := ref.lookup(helper.getUniqueKeyFunc(element))
 		if ok && helper.hardValidateFunc(verifyData, element) {
 			mu.Lock()
 			*successfullyValidated = append(*successfullyValidated, element)
@@ -145,6 +343,19 @@ func processFileUsingChannels(
 	successfullyValidated chan<- LocationData,
 	unsuccessfullyValidated chan<- LocationData,
 	utils HelperUtils,
+) {
+	processFileUsingChannelsAgainst(loadedReference(), tmpPath, wg, unprocessableFiles, successfullyValidated, unsuccessfullyValidated, utils)
+}
+
+// processFileUsingChannelsAgainst processFileUsingChannels validating against ref
+func processFileUsingChannelsAgainst(
+	ref *referenceData,
+	tmpPath string,
+	wg *sync.WaitGroup,
+	unprocessableFiles chan<- string,
+	successfullyValidated chan<- LocationData,
+	unsuccessfullyValidated chan<- LocationData,
+	utils HelperUtils,
 ) {
 	defer wg.Done()
 
@@ -155,7 +366,7 @@ func processFileUsingChannels(
 	}
 
 	for _, element := range cities {
-		verifyData, ok := authenticCities[utils.getUniqueKeyFunc(element)]
+		verifyData, ok := ref.lookup(utils.getUniqueKeyFunc(element))
 		if ok && utils.hardValidateFunc(element, verifyData) {
 			successfullyValidated <- element
 		} else {
@@ -165,45 +376,104 @@ func processFileUsingChannels(
 }
 
 func ProcessFilesWithoutMutex(tmpFolder string, utils HelperUtils) ([]LocationData, []LocationData, []string) {
-	var successfullyValidated, unsuccessfullyValidated []LocationData
-	var unprocessableFiles []string
+	return ProcessFilesWithoutMutexWith(tmpFolder, utils, false)
+}
+
+// ProcessFilesWithoutMutexWith is ProcessFilesWithoutMutex, with ordered the
+// records come back ordered by file path and record index. Every file then
+// gets its own channels, drained in path order; the files still load
+// concurrently and only wait once their buffers are full.
+func ProcessFilesWithoutMutexWith(tmpFolder string, utils HelperUtils, ordered bool) ([]LocationData, []LocationData, []string) {
+	var collected channelResults
 
+	ref := loadedReference()
 	allFiles, err := utils.getAllFiles(tmpFolder)
 	if err != nil {
-		fmt.Println("Error reading tmp folder:", err)
+		slog.Error("reading tmp folder", "dir", tmpFolder, "err", err)
 		return nil, nil, nil
 	}
 
-	var wg sync.WaitGroup
+	if ordered {
+		allFiles = slices.Clone(allFiles)
+		slices.Sort(allFiles)
+		perFile := make([]fileChannels, len(allFiles))
+		for i, fileP := range allFiles {
+			perFile[i] = startFileChannels([]string{fileP}, 10, ref, utils)
+		}
+		for _, channels := range perFile {
+			collected.drain(channels)
+		}
+		return collected.valid, collected.invalid, collected.unprocessable
+	}
 
-	unprocessableChan := make(chan string, len(allFiles))
-	authentic := make(chan LocationData, len(allFiles)*10) // Assuming each file has up to 10 cities
-	inauthentic := make(chan LocationData, len(allFiles)*10)
+	collected.drain(startFileChannels(allFiles, len(allFiles)*10, ref, utils)) // Assuming each file has up to 10 cities
+	return collected.valid, collected.invalid, collected.unprocessable
+}
+
+// fileChannels the channels processFileUsingChannels sends on, closed once
+// all of their files are done
+type fileChannels struct {
+	unprocessable          chan string
+	authentic, inauthentic chan LocationData
+}
 
-	for _, fileP := range allFiles {
+// startFileChannels validates files concurrently into new channels holding
+// up to buffer records each
+func startFileChannels(files []string, buffer int, ref *referenceData, utils HelperUtils) fileChannels {
+	channels := fileChannels{
+		unprocessable: make(chan string, len(files)),
+		authentic:     make(chan LocationData, buffer),
+		inauthentic:   make(chan LocationData, buffer),
+	}
+
+	var wg sync.WaitGroup
+	for _, fileP := range files {
 		wg.Add(1)
-		go processFileUsingChannels(fileP, &wg, unprocessableChan, authentic, inauthentic, utils)
+		go processFileUsingChannelsAgainst(ref, fileP, &wg, channels.unprocessable, channels.authentic, channels.inauthentic, utils)
 	}
 
 	// Close channels when all goroutines are done
 	go func() {
 		wg.Wait()
-		close(unprocessableChan)
-		close(authentic)
-		close(inauthentic)
+		close(channels.unprocessable)
+		close(channels.authentic)
+		close(channels.inauthentic)
 	}()
+	return channels
+}
 
-	for errMsg := range unprocessableChan {
-		unprocessableFiles = append(unprocessableFiles, errMsg)
-	}
-
-	for data := range authentic {
-		successfullyValidated = append(successfullyValidated, data)
-	}
+// channelResults what was received from fileChannels
+type channelResults struct {
+	valid, invalid []LocationData
+	unprocessable  []string
+}
 
-	for data := range inauthentic {
-		unsuccessfullyValidated = append(unsuccessfullyValidated, data)
+// drain receives from channels until all three are closed
+func (r *channelResults) drain(channels fileChannels) {
+	// Drain all three at once, files with more cities than the buffers hold
+	// would otherwise block the workers before the channels are closed.
+	// A receive from a nil channel never proceeds, so drained ones are set to nil.
+	unprocessed, valid, invalid := channels.unprocessable, channels.authentic, channels.inauthentic
+	for unprocessed != nil || valid != nil || invalid != nil {
+		select {
+		case errMsg, ok := <-unprocessed:
+			if !ok {
+				unprocessed = nil
+				continue
+			}
+			r.unprocessable = append(r.unprocessable, errMsg)
+		case data, ok := <-valid:
+			if !ok {
+				valid = nil
+				continue
+			}
+			r.valid = append(r.valid, data)
+		case data, ok := <-invalid:
+			if !ok {
+				invalid = nil
+				continue
+			}
+			r.invalid = append(r.invalid, data)
+		}
 	}
-
-	return successfullyValidated, unsuccessfullyValidated, unprocessableFiles
 }
@@ -6,10 +6,20 @@ import (
 	"os"
 	"path/filepath"
 	"reflect"
+	"strings"
 	"sync"
 	"testing"
 )
 
+// useReference publishes cities as the reference data, for tests that do not load a file
+func useReference(cities map[Key]LocationData, snapshot string) {
+	unique := make([]LocationData, 0, len(cities))
+	for _, city := range cities {
+		unique = append(unique, city)
+	}
+	publishReference(&referenceData{cities: cities, index: NewSpatialIndex(unique), snapshot: snapshot})
+}
+
 // Mock implementations of the functions
 
 func mockLoadDataToStruct(path string) ([]LocationData, error) {
@@ -25,6 +35,18 @@ func mockLoadDataToStruct(path string) ([]LocationData, error) {
 	}
 }
 
+// mockReadNothing reads nothing, the mock loaders go by the path alone
+func mockReadNothing(path string) ([]byte, error) {
+	return nil, nil
+}
+
+// mockParse a parseFunc that loads the path with load
+func mockParse(load func(string) ([]LocationData, error)) func(string, []byte) ([]LocationData, error) {
+	return func(path string, _ []byte) ([]LocationData, error) {
+		return load(path)
+	}
+}
+
 func mockGetUniqueKey(data LocationData) Key {
 	return Key{
 		City:    data.Name,
@@ -97,7 +119,7 @@ func TestProcessFile(t *testing.T) {
 
 	var wg sync.WaitGroup
 	var mu sync.Mutex
-	authenticCities = mockAuthenticCities
+	useReference(mockAuthenticCities, "")
 
 	// Test cases
 	tests := []struct {
@@ -119,8 +141,8 @@ func TestProcessFile(t *testing.T) {
 			var unprocessableFiles []string
 			var successfullyValidated []LocationData
 			var unsuccessfullyValidated []LocationData
-			authenticCities = mockAuthenticCities
-			mockUtils := HelperUtils{mockLoadDataToStruct, mockGetUniqueKey, mockHardValidate, nil}
+			useReference(mockAuthenticCities, "")
+			mockUtils := HelperUtils{mockReadNothing, mockParse(mockLoadDataToStruct), mockGetUniqueKey, mockHardValidate, nil}
 			wg.Add(1)
 			go processFile(
 				tt.tmpPath, &wg, &mu,
@@ -187,8 +209,8 @@ func TestLoadDataToStruct_ReadFileError(t *testing.T) {
 func TestLoadAuthenticCities(t *testing.T) {
 	t.Parallel()
 
-	// Reset the global authenticCities map before each test
-	authenticCities = nil
+	// Reset the reference data before each test
+	useReference(nil, "")
 
 	// Test cases
 	tests := []struct {
@@ -215,18 +237,18 @@ func TestLoadAuthenticCities(t *testing.T) {
 		tt := tt
 
 		t.Run(tt.name, func(t *testing.T) {
-			err := loadAuthenticCities(tt.filepath, mockLoadDataToStruct, mockGetUniqueKey)
+			_, err := loadAuthenticCities(tt.filepath, mockLoadDataToStruct, mockGetUniqueKey, FirstWins)
 			if (err != nil) != tt.expectError {
 				t.Errorf("Expected error: %v, got: %v", tt.expectError, err)
 			}
 
 			if !tt.expectError {
-				if len(authenticCities) != len(tt.expectedMap) {
-					t.Errorf("Expected map length: %d, got: %d", len(tt.expectedMap), len(authenticCities))
+				if len(loadedReference().cities) != len(tt.expectedMap) {
+					t.Errorf("Expected map length: %d, got: %d", len(tt.expectedMap), len(loadedReference().cities))
 				}
 
 				for key, expectedCity := range tt.expectedMap {
-					if city, ok := authenticCities[key]; !ok || city != expectedCity {
+					if city, ok := loadedReference().cities[key]; !ok || city != expectedCity {
 						t.Errorf("Expected city: %+v for key: %s, got: %+v", expectedCity, key, city)
 					}
 				}
@@ -358,8 +380,8 @@ func TestProcessFileUsingChannels_Success(t *testing.T) {
 	successfullyValidated := make(chan LocationData, 1)
 	unsuccessfullyValidated := make(chan LocationData, 1)
 
-	authenticCities = map[Key]LocationData{key1: city1}
-	mockUtils := HelperUtils{mockLoadDataFuncSuccess, mockGetUniqueKey, mockHardValidate, nil}
+	useReference(map[Key]LocationData{key1: city1}, "")
+	mockUtils := HelperUtils{mockReadNothing, mockParse(mockLoadDataFuncSuccess), mockGetUniqueKey, mockHardValidate, nil}
 
 	go processFileUsingChannels(
 		"mock/path",
@@ -392,7 +414,7 @@ func TestProcessFileUsingChannels_Failure(t *testing.T) {
 	unprocessableFiles := make(chan string, 1)
 	successfullyValidated := make(chan LocationData, 1)
 	unsuccessfullyValidated := make(chan LocationData, 1)
-	mockUtils := HelperUtils{mockLoadDataFuncFailure, mockGetUniqueKey, mockHardValidate, nil}
+	mockUtils := HelperUtils{mockReadNothing, mockParse(mockLoadDataFuncFailure), mockGetUniqueKey, mockHardValidate, nil}
 
 	go processFileUsingChannels(
 		"mock/path",
@@ -425,7 +447,7 @@ func TestProcessFileUsingChannels_PartialFailure(t *testing.T) {
 	unprocessableFiles := make(chan string, 1)
 	successfullyValidated := make(chan LocationData, 1)
 	unsuccessfullyValidated := make(chan LocationData, 1)
-	mockUtils := HelperUtils{mockLoadDataPartialFailure, mockGetUniqueKey, mockHardValidate, nil}
+	mockUtils := HelperUtils{mockReadNothing, mockParse(mockLoadDataPartialFailure), mockGetUniqueKey, mockHardValidate, nil}
 
 	go processFileUsingChannels(
 		"valid/Unsuccessful",
@@ -517,3 +539,60 @@ func TestGetAllFiles_ErrorReadingDir(t *testing.T) {
 		t.Error("Expected nil slice on error")
 	}
 }
+
+func TestLoadReferenceLayers(t *testing.T) {
+	oslo := LocationData{Name: "Oslo", Country: "Norway", Geo: "59.57, 10.45", Province: "Oslo"}
+	osloOverride := LocationData{Name: "Oslo", Country: "Norway", Geo: "59.57, 10.45", Province: "Oslo county", ProvinceIcon: "oslo.png"}
+	bergen := LocationData{Name: "Bergen", Country: "Norway", Geo: "60.23, 5.20"}
+	tromso := LocationData{Name: "Tromsø", Country: "Norway", Geo: "69.41, 18.57"}
+
+	layers := map[string][]LocationData{
+		"cities.json":           {oslo, bergen},
+		"overrides/eu.json":     {osloOverride, tromso},
+		"overrides/broken.json": nil,
+	}
+	loader := func(path string) ([]LocationData, error) {
+		if cities, ok := layers[path]; ok && cities != nil {
+			return cities, nil
+		}
+		return nil, errors.New("failed to load data")
+	}
+
+	conflicts, err := loadReferenceLayers([]string{"cities.json", "overrides/eu.json"}, loader, GetUniqueKey, FailOnDuplicate)
+	if err != nil {
+		t.Fatalf("overrides across layers are not duplicates, got %v", err)
+	}
+	if len(conflicts) != 0 {
+		t.Errorf("got conflicts %+v", conflicts)
+	}
+	if len(loadedReference().cities) != 3 {
+		t.Errorf("registry size = %d, want 3", len(loadedReference().cities))
+	}
+
+	tests := []struct {
+		city   LocationData
+		want   LocationData
+		source string
+	}{
+		{oslo, osloOverride, "overrides/eu.json"},
+		{bergen, bergen, "cities.json"},
+		{tromso, tromso, "overrides/eu.json"},
+	}
+	for _, tt := range tests {
+		key := GetUniqueKey(tt.city)
+		if got := loadedReference().cities[key]; got != tt.want {
+			t.Errorf("cities[%s] = %+v, want %+v", tt.city.Name, got, tt.want)
+		}
+		if got := loadedReference().source(key); got != tt.source {
+			t.Errorf("source(%s) = %q, want %q", tt.city.Name, got, tt.source)
+		}
+	}
+
+	_, err = loadReferenceLayers([]string{"cities.json", "overrides/broken.json"}, loader, GetUniqueKey, FirstWins)
+	if err == nil || !strings.Contains(err.Error(), "overrides/broken.json") {
+		t.Errorf("expected an error naming the broken layer, got %v", err)
+	}
+	if len(loadedReference().cities) != 3 {
+		t.Errorf("failed load replaced the registry")
+	}
+}
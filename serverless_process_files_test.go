@@ -1,89 +1,170 @@
 package main
 
-import "testing"
+import (
+	"reflect"
+	"testing"
+	"time"
+)
 
 func Test_processFiles(t *testing.T) {
-	authenticCities = map[Key]LocationData{key1: city1}
-	type args struct {
+	useReference(map[Key]LocationData{key1: city1}, "")
+	mocks := HelperUtils{readFunc: mockReadNothing, parseFunc: mockParse(mockLoadDataToStruct), getUniqueKeyFunc: mockGetUniqueKey, hardValidateFunc: mockHardValidate, getAllFiles: mockGetAllFiles}
+	missing := HelperUtils{readFunc: mockReadNothing, parseFunc: mockParse(mockLoadDataToStruct), getUniqueKeyFunc: mockGetUniqueKey, hardValidateFunc: mockHardValidate, getAllFiles: getAllFiles}
+
+	tests := []struct {
+		name      string
 		tmpFolder string
 		utils     HelperUtils
-	}
-	tests := []struct {
-		name string
-		args args
+		want      EngineResult
 	}{
 		{
-			name: "successful",
-			args: args{
-				tmpFolder: "valid/path",
-				utils:     HelperUtils{loadDataFunc: mockLoadDataToStruct, getUniqueKeyFunc: mockGetUniqueKey, hardValidateFunc: mockHardValidate, getAllFiles: mockGetAllFiles},
+			name:      "successful",
+			tmpFolder: "valid/path",
+			utils:     mocks,
+			want: EngineResult{
+				Valid:         []LocationData{{Name: "ValidCity1"}, {Name: "ValidCity1"}},
+				Invalid:       []LocationData{{Name: "ValidCity2"}, {Name: "invalid"}},
+				Unprocessable: []string{"invalid/path"},
 			},
 		},
 		{
-			name: "no files present",
-			args: args{
-				tmpFolder: "invalid/path",
-				utils:     HelperUtils{loadDataFunc: mockLoadDataToStruct, getUniqueKeyFunc: mockGetUniqueKey, hardValidateFunc: mockHardValidate, getAllFiles: getAllFiles},
-			},
+			name:      "no files present",
+			tmpFolder: "invalid/path",
+			utils:     missing,
 		},
 	}
-	for _, tt := range tests {
-		t.Run(tt.name, func(t *testing.T) {
-			success, unsuccessful, invalid := ProcessFiles(tt.args.tmpFolder, tt.args.utils)
+	for _, process := range []struct {
+		name string
+		fn   func(string, HelperUtils) ([]LocationData, []LocationData, []string)
+	}{
+		{"ProcessFiles", ProcessFiles},
+		{"ProcessFilesWithoutMutex", ProcessFilesWithoutMutex},
+	} {
+		for _, tt := range tests {
+			t.Run(process.name+"/"+tt.name, func(t *testing.T) {
+				success, unsuccessful, unprocessable := process.fn(tt.tmpFolder, tt.utils)
+				got := sortedResult(EngineResult{success, unsuccessful, unprocessable})
+				if !reflect.DeepEqual(got, tt.want) {
+					t.Errorf("%s() = %+v, want %+v", process.name, got, tt.want)
+				}
+			})
+		}
+	}
+}
+
+func Test_processFileResults(t *testing.T) {
+	useReference(map[Key]LocationData{key1: city1}, "")
+	utils := HelperUtils{readFunc: mockReadNothing, parseFunc: mockParse(mockLoadDataToStruct), getUniqueKeyFunc: mockGetUniqueKey, hardValidateFunc: mockHardValidate, getAllFiles: mockGetAllFiles}
+
+	results, err := ProcessFileResults("valid/path", utils)
+	if err != nil {
+		t.Fatalf("ProcessFileResults() error = %v", err)
+	}
 
-			valid := tt.args.tmpFolder == "valid/path"
-			if valid && len(success) == 0 {
-				t.Errorf("ProcessFiles() return empty for sucess")
+	tests := []struct {
+		path        string
+		wantInvalid []int
+		wantErr     bool
+	}{
+		{path: "invalid/path", wantErr: true},
+		{path: "valid/Unsuccessful", wantInvalid: []int{1}},
+		{path: "valid/path", wantInvalid: []int{1}},
+	}
+	if len(results) != len(tests) {
+		t.Fatalf("ProcessFileResults() returned %d results, want %d", len(results), len(tests))
+	}
+	for i, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			got := results[i]
+			if got.Path != tt.path || (got.Err != nil) != tt.wantErr {
+				t.Fatalf("ProcessFileResults()[%d] = %s (err %v), want %s", i, got.Path, got.Err, tt.path)
 			}
-			if valid && len(unsuccessful) == 0 {
-				t.Errorf("ProcessFiles() return empty for unsuccessful")
+			var invalid []int
+			for _, record := range got.Invalid() {
+				invalid = append(invalid, record.Index)
 			}
-			if valid && len(invalid) == 0 {
-				t.Errorf("ProcessFiles() return empty for invalid")
+			if !reflect.DeepEqual(invalid, tt.wantInvalid) {
+				t.Errorf("ProcessFileResults()[%d] invalid = %v, want %v", i, invalid, tt.wantInvalid)
 			}
 		})
 	}
-}
 
-func Test_processFilesC(t *testing.T) {
-	authenticCities = map[Key]LocationData{key1: city1}
-	type args struct {
-		tmpFolder string
-		utils     HelperUtils
+	success, unsuccessful, unprocessable := splitResults(results)
+	if len(success) != 2 || len(unsuccessful) != 2 || len(unprocessable) != 1 {
+		t.Errorf("splitResults() = %d, %d, %d, want 2, 2, 1", len(success), len(unsuccessful), len(unprocessable))
 	}
-	tests := []struct {
+}
+
+func Test_processFilesOrdered(t *testing.T) {
+	useReference(map[Key]LocationData{key1: city1}, "")
+	utils := HelperUtils{readFunc: mockReadNothing, parseFunc: mockParse(mockLoadDataToStruct), getUniqueKeyFunc: mockGetUniqueKey, hardValidateFunc: mockHardValidate,
+		getAllFiles: func(string) ([]string, error) {
+			return []string{"valid/path", "invalid/path", "valid/Unsuccessful"}, nil
+		}}
+
+	wantSuccess := []LocationData{{Name: "ValidCity1"}, {Name: "ValidCity1"}}
+	wantUnsuccessful := []LocationData{{Name: "invalid"}, {Name: "ValidCity2"}}
+	wantUnprocessable := []string{"invalid/path"}
+
+	for _, process := range []struct {
 		name string
-		args args
+		fn   func(string, HelperUtils) ([]LocationData, []LocationData, []string)
 	}{
-		{
-			name: "successful",
-			args: args{
-				tmpFolder: "valid/path",
-				utils:     HelperUtils{loadDataFunc: mockLoadDataToStruct, getUniqueKeyFunc: mockGetUniqueKey, hardValidateFunc: mockHardValidate, getAllFiles: mockGetAllFiles},
-			},
-		},
-		{
-			name: "no files present",
-			args: args{
-				tmpFolder: "invalid/path",
-				utils:     HelperUtils{loadDataFunc: mockLoadDataToStruct, getUniqueKeyFunc: mockGetUniqueKey, hardValidateFunc: mockHardValidate, getAllFiles: getAllFiles},
-			},
-		},
-	}
-	for _, tt := range tests {
-		t.Run(tt.name, func(t *testing.T) {
-			success, unsuccessful, invalid := ProcessFilesWithoutMutex(tt.args.tmpFolder, tt.args.utils)
-			valid := tt.args.tmpFolder == "valid/path"
-			if valid && len(success) == 0 {
-				t.Errorf("ProcessFiles() return empty for sucess")
-			}
-			if valid && len(unsuccessful) == 0 {
-				t.Errorf("ProcessFiles() return empty for unsuccessful")
-			}
-			if valid && len(invalid) == 0 {
-				t.Errorf("ProcessFiles() return empty for invalid")
+		{"ProcessFilesOrdered", ProcessFilesOrdered},
+		{"ProcessFilesWith", func(dir string, utils HelperUtils) ([]LocationData, []LocationData, []string) {
+			return ProcessFilesWith(dir, utils, true)
+		}},
+		{"ProcessFilesWithoutMutexWith", func(dir string, utils HelperUtils) ([]LocationData, []LocationData, []string) {
+			return ProcessFilesWithoutMutexWith(dir, utils, true)
+		}},
+	} {
+		for i := 0; i < 20; i++ {
+			success, unsuccessful, unprocessable := process.fn("valid/path", utils)
+			if !reflect.DeepEqual(success, wantSuccess) || !reflect.DeepEqual(unsuccessful, wantUnsuccessful) || !reflect.DeepEqual(unprocessable, wantUnprocessable) {
+				t.Fatalf("%s() = %v, %v, %v, want %v, %v, %v", process.name,
+					success, unsuccessful, unprocessable, wantSuccess, wantUnsuccessful, wantUnprocessable)
 			}
+		}
+	}
 
-		})
+	results, err := ProcessFileResultsWith("valid/path", utils, RunOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	success, unsuccessful, unprocessable := splitResults(results)
+	if len(success) != len(wantSuccess) || len(unsuccessful) != len(wantUnsuccessful) || len(unprocessable) != len(wantUnprocessable) {
+		t.Errorf("unordered run = %v, %v, %v, want the same records as the ordered one", success, unsuccessful, unprocessable)
+	}
+}
+
+func Test_processFilesWithoutMutexLargeFiles(t *testing.T) {
+	useReference(map[Key]LocationData{GetUniqueKey(oslo): oslo}, "")
+	// far more records than the channel buffers of 10 per file hold
+	var cities []LocationData
+	for i := 0; i < 50; i++ {
+		cities = append(cities, oslo, rabat)
+	}
+	utils := HelperUtils{
+		readFunc:         mockReadNothing,
+		parseFunc:        func(string, []byte) ([]LocationData, error) { return cities, nil },
+		getUniqueKeyFunc: GetUniqueKey,
+		hardValidateFunc: hardCheck,
+		getAllFiles:      func(string) ([]string, error) { return []string{"city-1.json"}, nil },
+	}
+
+	for _, ordered := range []bool{false, true} {
+		done := make(chan [2]int)
+		go func() {
+			valid, invalid, _ := ProcessFilesWithoutMutexWith("tmp", utils, ordered)
+			done <- [2]int{len(valid), len(invalid)}
+		}()
+		select {
+		case got := <-done:
+			if got != [2]int{50, 50} {
+				t.Errorf("ProcessFilesWithoutMutexWith(ordered %v) = %d valid, %d invalid, want 50 and 50", ordered, got[0], got[1])
+			}
+		case <-time.After(10 * time.Second):
+			t.Fatalf("ProcessFilesWithoutMutexWith(ordered %v) deadlocked on a file larger than the channel buffers", ordered)
+		}
 	}
 }
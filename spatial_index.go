@@ -0,0 +1,181 @@
+package main
+
+import (
+	"errors"
+	"math"
+	"sort"
+)
+
+const earthRadiusKm = 6371.0
+
+// Neighbor reference city returned by a spatial query
+type Neighbor struct {
+	City       LocationData `json:"city"`
+	Point      GeoPoint     `json:"point"`
+	DistanceKm float64      `json:"distance_km"`
+}
+
+// SpatialIndex k-d tree over reference cities. Points are stored as unit
+// vectors so distances stay correct across the poles and the antimeridian.
+type SpatialIndex struct {
+	root *kdNode
+	size int
+}
+
+type kdNode struct {
+	city        LocationData
+	point       GeoPoint
+	xyz         [3]float64
+	axis        int
+	left, right *kdNode
+}
+
+// NewSpatialIndex builds the index from the given cities. Records whose
+// hemisphere cannot be resolved are left out, ambiguous ones are indexed at
+// their northern/eastern candidate.
+func NewSpatialIndex(cities []LocationData) *SpatialIndex {
+	nodes := make([]*kdNode, 0, len(cities))
+	for _, city := range cities {
+		point, err := ResolveGeoPoint(city)
+		if err != nil && !errors.Is(err, ErrHemisphereAmbiguous) {
+			continue
+		}
+		nodes = append(nodes, &kdNode{city: city, point: point, xyz: toXYZ(point)})
+	}
+
+	return &SpatialIndex{root: buildKDTree(nodes, 0), size: len(nodes)}
+}
+
+func buildKDTree(nodes []*kdNode, depth int) *kdNode {
+	if len(nodes) == 0 {
+		return nil
+	}
+
+	axis := depth % 3
+	sort.Slice(nodes, func(i, j int) bool { return nodes[i].xyz[axis] < nodes[j].xyz[axis] })
+
+	mid := len(nodes) / 2
+	node := nodes[mid]
+	node.axis = axis
+	node.left = buildKDTree(nodes[:mid], depth+1)
+	node.right = buildKDTree(nodes[mid+1:], depth+1)
+	return node
+}
+
+// Len number of indexed cities
+func (s *SpatialIndex) Len() int {
+	if s == nil {
+		return 0
+	}
+	return s.size
+}
+
+// Nearest returns up to n reference cities closest to p, nearest first.
+func (s *SpatialIndex) Nearest(p GeoPoint, n int) []Neighbor {
+	if s == nil || n <= 0 {
+		return nil
+	}
+
+	target := toXYZ(p)
+	var best []*kdNode
+	var bestDist []float64
+
+	var search func(node *kdNode)
+	search = func(node *kdNode) {
+		if node == nil {
+			return
+		}
+
+		d := chordSquared(target, node.xyz)
+		if len(best) < n || d < bestDist[len(bestDist)-1] {
+			i := sort.SearchFloat64s(bestDist, d)
+			best = append(best, nil)
+			bestDist = append(bestDist, 0)
+			copy(best[i+1:], best[i:])
+			copy(bestDist[i+1:], bestDist[i:])
+			best[i], bestDist[i] = node, d
+			if len(best) > n {
+				best, bestDist = best[:n], bestDist[:n]
+			}
+		}
+
+		diff := target[node.axis] - node.xyz[node.axis]
+		near, far := node.left, node.right
+		if diff > 0 {
+			near, far = far, near
+		}
+		search(near)
+		if len(best) < n || diff*diff < bestDist[len(bestDist)-1] {
+			search(far)
+		}
+	}
+	search(s.root)
+
+	neighbors := make([]Neighbor, len(best))
+	for i, node := range best {
+		neighbors[i] = Neighbor{City: node.city, Point: node.point, DistanceKm: haversineKm(p, node.point)}
+	}
+	return neighbors
+}
+
+// Within returns every reference city at most radiusKm away from p, nearest first.
+func (s *SpatialIndex) Within(p GeoPoint, radiusKm float64) []Neighbor {
+	if s == nil || radiusKm < 0 {
+		return nil
+	}
+
+	// compare squared chord lengths, the great circle radius is converted once
+	limit := 2 * math.Sin(math.Min(radiusKm/earthRadiusKm, math.Pi)/2)
+	limit *= limit
+	target := toXYZ(p)
+
+	var neighbors []Neighbor
+	var search func(node *kdNode)
+	search = func(node *kdNode) {
+		if node == nil {
+			return
+		}
+
+		if chordSquared(target, node.xyz) <= limit {
+			neighbors = append(neighbors, Neighbor{City: node.city, Point: node.point, DistanceKm: haversineKm(p, node.point)})
+		}
+
+		diff := target[node.axis] - node.xyz[node.axis]
+		if diff <= 0 || diff*diff <= limit {
+			search(node.left)
+		}
+		if diff >= 0 || diff*diff <= limit {
+			search(node.right)
+		}
+	}
+	search(s.root)
+
+	sort.Slice(neighbors, func(i, j int) bool { return neighbors[i].DistanceKm < neighbors[j].DistanceKm })
+	return neighbors
+}
+
+func toXYZ(p GeoPoint) [3]float64 {
+	lat := p.Lat * math.Pi / 180
+	lon := p.Lon * math.Pi / 180
+	return [3]float64{
+		math.Cos(lat) * math.Cos(lon),
+		math.Cos(lat) * math.Sin(lon),
+		math.Sin(lat),
+	}
+}
+
+func chordSquared(a, b [3]float64) float64 {
+	dx, dy, dz := a[0]-b[0], a[1]-b[1], a[2]-b[2]
+	return dx*dx + dy*dy + dz*dz
+}
+
+// haversineKm great circle distance between two points in kilometres
+func haversineKm(a, b GeoPoint) float64 {
+	lat1 := a.Lat * math.Pi / 180
+	lat2 := b.Lat * math.Pi / 180
+	dLat := lat2 - lat1
+	dLon := (b.Lon - a.Lon) * math.Pi / 180
+
+	h := math.Sin(dLat/2)*math.Sin(dLat/2) + math.Cos(lat1)*math.Cos(lat2)*math.Sin(dLon/2)*math.Sin(dLon/2)
+	return 2 * earthRadiusKm * math.Asin(math.Min(1, math.Sqrt(h)))
+}
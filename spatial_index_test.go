@@ -0,0 +1,107 @@
+package main
+
+import (
+	"errors"
+	"math"
+	"sort"
+	"testing"
+)
+
+func loadReferenceFixture(t *testing.T) []LocationData {
+	t.Helper()
+	cities, err := loadDataToStruct("cities.json")
+	if err != nil {
+		t.Fatalf("Failed to load cities.json: %v", err)
+	}
+	return cities
+}
+
+func bruteForceNeighbors(cities []LocationData, p GeoPoint) []Neighbor {
+	var all []Neighbor
+	for _, city := range cities {
+		point, err := ResolveGeoPoint(city)
+		if err != nil && !errors.Is(err, ErrHemisphereAmbiguous) {
+			continue
+		}
+		all = append(all, Neighbor{City: city, Point: point, DistanceKm: haversineKm(p, point)})
+	}
+	sort.Slice(all, func(i, j int) bool { return all[i].DistanceKm < all[j].DistanceKm })
+	return all
+}
+
+var spatialQueryPoints = []GeoPoint{
+	{Lat: 25.40, Lon: -100.18}, // Monterrey
+	{Lat: 51.50, Lon: -0.12},   // London
+	{Lat: -33.87, Lon: 151.21}, // Sydney
+	{Lat: -17.5, Lon: 179.9},   // next to the antimeridian
+	{Lat: 89.0, Lon: 0},        // close to the pole
+	{Lat: 0, Lon: -150},        // open ocean
+}
+
+func TestSpatialIndex_Nearest(t *testing.T) {
+	cities := loadReferenceFixture(t)
+	index := NewSpatialIndex(cities)
+
+	for _, p := range spatialQueryPoints {
+		want := bruteForceNeighbors(cities, p)[:5]
+		got := index.Nearest(p, 5)
+		if len(got) != len(want) {
+			t.Fatalf("Nearest(%+v) returned %d cities, want %d", p, len(got), len(want))
+		}
+		for i := range want {
+			if math.Abs(got[i].DistanceKm-want[i].DistanceKm) > 1e-6 {
+				t.Errorf("Nearest(%+v)[%d] = %s at %.1f km, want %s at %.1f km",
+					p, i, got[i].City.Name, got[i].DistanceKm, want[i].City.Name, want[i].DistanceKm)
+			}
+		}
+	}
+}
+
+func TestSpatialIndex_Within(t *testing.T) {
+	cities := loadReferenceFixture(t)
+	index := NewSpatialIndex(cities)
+
+	for _, radius := range []float64{0, 150, 800, 2500} {
+		for _, p := range spatialQueryPoints {
+			var want int
+			for _, n := range bruteForceNeighbors(cities, p) {
+				if n.DistanceKm <= radius {
+					want++
+				}
+			}
+			got := index.Within(p, radius)
+			if len(got) != want {
+				t.Errorf("Within(%+v, %v) returned %d cities, want %d", p, radius, len(got), want)
+			}
+			if !sort.SliceIsSorted(got, func(i, j int) bool { return got[i].DistanceKm < got[j].DistanceKm }) {
+				t.Errorf("Within(%+v, %v) is not ordered by distance", p, radius)
+			}
+		}
+	}
+}
+
+func TestSpatialIndex_Empty(t *testing.T) {
+	var nilIndex *SpatialIndex
+	if got := nilIndex.Nearest(GeoPoint{}, 3); got != nil {
+		t.Errorf("Nearest() on nil index = %v, want nil", got)
+	}
+
+	index := NewSpatialIndex([]LocationData{{Name: "no coordinates"}})
+	if index.Len() != 0 {
+		t.Errorf("Len() = %d, want 0", index.Len())
+	}
+	if got := index.Within(GeoPoint{}, 100); len(got) != 0 {
+		t.Errorf("Within() on empty index = %v, want none", got)
+	}
+}
+
+func TestHaversineKm(t *testing.T) {
+	london := GeoPoint{Lat: 51.5074, Lon: -0.1278}
+	paris := GeoPoint{Lat: 48.8566, Lon: 2.3522}
+	if got := haversineKm(london, paris); math.Abs(got-343.5) > 1 {
+		t.Errorf("haversineKm(London, Paris) = %.1f, want ~343.5", got)
+	}
+	if got := haversineKm(GeoPoint{Lon: 179.5}, GeoPoint{Lon: -179.5}); math.Abs(got-111.2) > 0.5 {
+		t.Errorf("haversineKm across the antimeridian = %.1f, want ~111.2", got)
+	}
+}
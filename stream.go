@@ -0,0 +1,180 @@
+package main
+
+import (
+	"context"
+	"runtime"
+	"slices"
+	"sort"
+	"sync"
+)
+
+// EventKind what a ResultEvent reports
+type EventKind string
+
+const (
+	EventRecordValid       EventKind = "record_valid"
+	EventRecordInvalid     EventKind = "record_invalid"
+	EventFileUnprocessable EventKind = "file_unprocessable"
+	EventFileDone          EventKind = "file_done" // follows the other events of every file
+)
+
+// ResultEvent one outcome of a streaming run
+type ResultEvent struct {
+	Kind    EventKind     `json:"kind"`
+	File    string        `json:"file"`
+	Index   int           `json:"index"`            // record events
+	Record  *LocationData `json:"record,omitempty"` // record events
+	Error   string        `json:"error,omitempty"`  // EventFileUnprocessable
+	Valid   int           `json:"valid,omitempty"`  // EventFileDone
+	Invalid int           `json:"invalid,omitempty"`
+}
+
+// ResultHandler receives the events of a run. It is never called concurrently,
+// returning an error stops the run and StreamFiles returns that error.
+type ResultHandler func(ResultEvent) error
+
+// StreamFiles validates the files of tmpFolder and passes every outcome to
+// handle as soon as it is known, instead of collecting them. Only about
+// opts.Workers files are held in memory at a time. With opts.Ordered the
+// events come in file path and record order. The whole run validates against
+// the reference data loaded when it started, even if handle reloads it.
+func StreamFiles(ctx context.Context, tmpFolder string, helpers HelperUtils, opts RunOptions, handle ResultHandler) error {
+	return forEachFileResult(ctx, tmpFolder, helpers, opts, func(result FileResult) error {
+		return emitFileEvents(result, handle)
+	})
+}
+
+// emitFileEvents hands the events of one file to handle
+func emitFileEvents(result FileResult, handle ResultHandler) error {
+	done := ResultEvent{Kind: EventFileDone, File: result.Path}
+	if result.Err != nil {
+		if err := handle(ResultEvent{Kind: EventFileUnprocessable, File: result.Path, Error: result.Err.Error()}); err != nil {
+			return err
+		}
+		return handle(done)
+	}
+
+	for i := range result.Records {
+		record := &result.Records[i]
+		event := ResultEvent{Kind: EventRecordInvalid, File: result.Path, Index: record.Index, Record: &record.Record}
+		if record.Valid {
+			event.Kind = EventRecordValid
+			done.Valid++
+		} else {
+			done.Invalid++
+		}
+		if err := handle(event); err != nil {
+			return err
+		}
+	}
+	return handle(done)
+}
+
+// forEachFileResult validates the files of tmpFolder with a pool of workers and
+// calls fn with the result of every file from the calling goroutine. It stops
+// at the first error of fn or when ctx is done.
+func forEachFileResult(ctx context.Context, tmpFolder string, helpers HelperUtils, opts RunOptions, fn func(FileResult) error) error {
+	opts.reference = loadedReference()
+	allFiles, err := helpers.getAllFiles(tmpFolder)
+	if err != nil {
+		return err
+	}
+	opts.Checkpoint.begin(tmpFolder, opts.reference.snapshot)
+	if opts.Ordered {
+		allFiles = slices.Clone(allFiles)
+		sort.Strings(allFiles)
+	}
+	workers := opts.Workers
+	if workers <= 0 {
+		workers = runtime.GOMAXPROCS(0)
+	}
+	opts.Progress.begin(len(allFiles))
+	defer opts.Progress.finish()
+
+	runCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var fnErr error
+	collect := func(result FileResult) {
+		if fnErr != nil || runCtx.Err() != nil {
+			return // drain what is still in flight
+		}
+		if fnErr = fn(result); fnErr != nil {
+			cancel()
+		}
+	}
+
+	if opts.Ordered {
+		streamOrdered(runCtx, allFiles, workers, helpers, opts, collect)
+	} else {
+		streamUnordered(runCtx, allFiles, workers, helpers, opts, collect)
+	}
+
+	if fnErr != nil {
+		return fnErr
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return opts.Checkpoint.Err()
+}
+
+// streamOrdered keeps a window of workers files in flight, each with its own
+// slot, and collects the slots in file order
+func streamOrdered(ctx context.Context, files []string, workers int, helpers HelperUtils, opts RunOptions, collect func(FileResult)) {
+	slots := make(chan chan FileResult, workers)
+	go func() {
+		defer close(slots)
+		for _, path := range files {
+			slot := make(chan FileResult, 1)
+			select {
+			case slots <- slot:
+			case <-ctx.Done():
+				return
+			}
+			go func(path string) {
+				slot <- opts.processFile(path, helpers)
+			}(path)
+		}
+	}()
+
+	for slot := range slots {
+		collect(<-slot)
+	}
+}
+
+// streamUnordered collects the results as the workers finish them
+func streamUnordered(ctx context.Context, files []string, workers int, helpers HelperUtils, opts RunOptions, collect func(FileResult)) {
+	paths := make(chan string)
+	results := make(chan FileResult)
+
+	go func() {
+		defer close(paths)
+		for _, path := range files {
+			select {
+			case paths <- path:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for path := range paths {
+				results <- opts.processFile(path, helpers)
+			}
+		}()
+	}
+	go func() {
+		wg.Wait()
+		close(results)
+	}()
+
+	for result := range results {
+		collect(result)
+	}
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestStreamFiles(t *testing.T) {
+	useReference(map[Key]LocationData{GetUniqueKey(oslo): oslo}, "")
+	dir := t.TempDir()
+	writeReferenceFile(t, filepath.Join(dir, "city-1.json"), []LocationData{oslo, rabat})
+	if err := os.WriteFile(filepath.Join(dir, "city-2.json"), []byte("[{"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	writeReferenceFile(t, filepath.Join(dir, "city-3.json"), []LocationData{oslo})
+	helpers := HelperUtils{readData, parseCityFile, GetUniqueKey, hardCheck, getAllFiles}
+
+	var got []string
+	err := StreamFiles(context.Background(), dir, helpers, RunOptions{Ordered: true, Workers: 2}, func(event ResultEvent) error {
+		got = append(got, fmt.Sprintf("%s %s %d %d/%d", event.Kind, filepath.Base(event.File), event.Index, event.Valid, event.Invalid))
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("StreamFiles() error = %v", err)
+	}
+
+	want := []string{
+		"record_valid city-1.json 0 0/0",
+		"record_invalid city-1.json 1 0/0",
+		"file_done city-1.json 0 1/1",
+		"file_unprocessable city-2.json 0 0/0",
+		"file_done city-2.json 0 0/0",
+		"record_valid city-3.json 0 0/0",
+		"file_done city-3.json 0 1/0",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("StreamFiles() events = %v, want %v", got, want)
+	}
+}
+
+func TestStreamFilesStops(t *testing.T) {
+	useReference(map[Key]LocationData{GetUniqueKey(oslo): oslo}, "")
+	dir := t.TempDir()
+	for i := 0; i < 50; i++ {
+		writeReferenceFile(t, filepath.Join(dir, fmt.Sprintf("city-%02d.json", i)), []LocationData{oslo})
+	}
+	helpers := HelperUtils{readData, parseCityFile, GetUniqueKey, hardCheck, getAllFiles}
+	errStop := errors.New("stop")
+
+	tests := []struct {
+		name    string
+		ordered bool
+		cancel  bool
+		wantErr error
+	}{
+		{"handler error ordered", true, false, errStop},
+		{"handler error unordered", false, false, errStop},
+		{"cancelled ordered", true, true, context.Canceled},
+		{"cancelled unordered", false, true, context.Canceled},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			files := 0
+			err := StreamFiles(ctx, dir, helpers, RunOptions{Ordered: tt.ordered, Workers: 2}, func(event ResultEvent) error {
+				if event.Kind != EventFileDone {
+					return nil
+				}
+				files++
+				if files < 3 {
+					return nil
+				}
+				if tt.cancel {
+					cancel()
+					return nil
+				}
+				return errStop
+			})
+
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("StreamFiles() error = %v, want %v", err, tt.wantErr)
+			}
+			if files != 3 {
+				t.Errorf("handler saw %d files after stopping at 3", files)
+			}
+		})
+	}
+}
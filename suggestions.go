@@ -0,0 +1,143 @@
+package main
+
+import (
+	"errors"
+	"math"
+)
+
+// minSuggestionConfidence suggestions scoring below this are not reported
+const minSuggestionConfidence = 0.5
+
+// maxFuzzyConfidence best score of a suggestion whose key does not match, so
+// that only an exact match has confidence 1
+const maxFuzzyConfidence = 0.999
+
+// suggestionRadiusKm distance at which the proximity part of the score drops to zero
+const suggestionRadiusKm = 500.0
+
+// Suggestion most likely reference entry for a record that failed validation
+type Suggestion struct {
+	Reference  LocationData `json:"reference"`
+	Source     string       `json:"source,omitempty"` // reference file the entry came from
+	Confidence float64      `json:"confidence"`       // 0..1, only 1 when the key matched exactly
+}
+
+// InvalidResult record that failed validation with the closest reference entry, if any
+type InvalidResult struct {
+	Record     LocationData    `json:"record"`
+	Location   *RecordLocation `json:"location,omitempty"` // unknown for the engines that only return records
+	Suggestion *Suggestion     `json:"suggestion,omitempty"`
+}
+
+// AttachSuggestions pairs every invalid record with its best reference match.
+func AttachSuggestions(invalid []LocationData) []InvalidResult {
+	return loadedReference().attachSuggestions(invalid)
+}
+
+func (ref *referenceData) attachSuggestions(invalid []LocationData) []InvalidResult {
+	results := make([]InvalidResult, 0, len(invalid))
+	for _, record := range invalid {
+		result := InvalidResult{Record: record}
+		if suggestion, ok := ref.suggest(record); ok {
+			result.Suggestion = &suggestion
+		}
+		results = append(results, result)
+	}
+	return results
+}
+
+// SuggestReference looks for the reference entry the record most likely meant.
+// Names are compared after folding case and diacritics ("Aaiun" matches
+// "Aaiún"), the country and the distance between both positions are weighed
+// in. A record with a position is first compared with the reference entries
+// within suggestionRadiusKm of it, which the spatial index finds. When none of
+// them is similar enough, or the record has no usable position, every entry
+// is compared by name and country alone.
+func SuggestReference(record LocationData) (Suggestion, bool) {
+	return loadedReference().suggest(record)
+}
+
+func (reference *referenceData) suggest(record LocationData) (Suggestion, bool) {
+	if ref, ok := reference.lookup(GetUniqueKey(record)); ok {
+		return Suggestion{Reference: ref, Source: reference.source(GetUniqueKey(ref)), Confidence: 1}, true
+	}
+
+	name := foldName(record.Name)
+	country := foldName(record.Country)
+	var best Suggestion
+	consider := func(ref LocationData, confidence float64) {
+		if confidence > best.Confidence || (confidence == best.Confidence && keyLess(GetUniqueKey(ref), GetUniqueKey(best.Reference))) {
+			best = Suggestion{Reference: ref, Confidence: confidence}
+		}
+	}
+
+	point, err := ResolveGeoPoint(record)
+	if err == nil || errors.Is(err, ErrHemisphereAmbiguous) {
+		for _, neighbor := range reference.index.Within(point, suggestionRadiusKm) {
+			proximity := math.Max(0, 1-neighbor.DistanceKm/suggestionRadiusKm)
+			consider(neighbor.City, 0.6*similarity(name, foldName(neighbor.City.Name))+0.2*similarity(country, foldName(neighbor.City.Country))+0.2*proximity)
+		}
+	}
+	if best.Confidence < minSuggestionConfidence {
+		// no usable position or nothing similar near it, e.g. a mistyped latitude
+		for _, ref := range reference.cities {
+			consider(ref, 0.75*similarity(name, foldName(ref.Name))+0.25*similarity(country, foldName(ref.Country)))
+		}
+	}
+
+	if best.Confidence < minSuggestionConfidence {
+		return Suggestion{}, false
+	}
+	best.Confidence = math.Min(math.Round(best.Confidence*1000)/1000, maxFuzzyConfidence)
+	best.Source = reference.source(GetUniqueKey(best.Reference))
+	return best, true
+}
+
+func keyLess(a, b Key) bool {
+	if a.City != b.City {
+		return a.City < b.City
+	}
+	if a.Country != b.Country {
+		return a.Country < b.Country
+	}
+	return a.Geo < b.Geo
+}
+
+// fuzzyNormalization loosest normalization, only used to score suggestions
+var fuzzyNormalization = NormalizeOptions{Form: FormNFKC, CaseFold: true, TrimSpace: true, StripDiacritics: true}
+
+// foldName lower-cases, strips diacritics and collapses whitespace
+func foldName(s string) string {
+	return fuzzyNormalization.Apply(s)
+}
+
+// similarity 1 - normalised Levenshtein distance between a and b
+func similarity(a, b string) float64 {
+	ra, rb := []rune(a), []rune(b)
+	longest := max(len(ra), len(rb))
+	if longest == 0 {
+		return 1
+	}
+	return 1 - float64(levenshtein(ra, rb))/float64(longest)
+}
+
+func levenshtein(a, b []rune) int {
+	prev := make([]int, len(b)+1)
+	curr := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+
+	for i := 1; i <= len(a); i++ {
+		curr[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			curr[j] = min(prev[j]+1, curr[j-1]+1, prev[j-1]+cost)
+		}
+		prev, curr = curr, prev
+	}
+	return prev[len(b)]
+}
@@ -0,0 +1,146 @@
+package main
+
+import "testing"
+
+var (
+	elAaiun = LocationData{Latitude: "27.09", Longitude: "13.12", Geo: "27.09, 13.12", Name: "El Aaiún", Country: "Morocco"}
+	rabat   = LocationData{Latitude: "34.02", Longitude: "6.50", Geo: "34.02, 6.50", Name: "Rabat", Country: "Morocco"}
+	oslo    = LocationData{Latitude: "59.57", Longitude: "10.45", Geo: "59.57, 10.45", Name: "Oslo", Country: "Norway"}
+	bergen  = LocationData{Latitude: "60.23", Longitude: "5.20", Geo: "60.23, 5.20", Name: "Bergen", Country: "Norway"}
+)
+
+func TestFoldName(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{"El Aaiún", "el aaiun"},
+		{"  Nuevo   León ", "nuevo leon"},
+		{"Nuevo León", "nuevo leon"},
+		{"São Tomé and Príncipe", "sao tome and principe"},
+		{"", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			if got := foldName(tt.in); got != tt.want {
+				t.Errorf("foldName(%q) = %q, want %q", tt.in, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSimilarity(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want float64
+	}{
+		{"oslo", "oslo", 1},
+		{"", "", 1},
+		{"oslo", "olso", 0.5},
+		{"abc", "", 0},
+	}
+	for _, tt := range tests {
+		if got := similarity(tt.a, tt.b); got != tt.want {
+			t.Errorf("similarity(%q, %q) = %v, want %v", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
+
+func TestSuggestReference(t *testing.T) {
+	useReference(map[Key]LocationData{
+		GetUniqueKey(elAaiun): elAaiun,
+		GetUniqueKey(rabat):   rabat,
+		GetUniqueKey(oslo):    oslo,
+		GetUniqueKey(bergen):  bergen,
+	}, "")
+
+	tests := []struct {
+		name           string
+		record         LocationData
+		want           LocationData
+		wantOK         bool
+		wantConfidence float64
+	}{
+		{
+			name:           "key matches but other fields differ",
+			record:         LocationData{Latitude: "59.57", Longitude: "10.45", Geo: "59.57, 10.45", Name: "Oslo", Country: "Norway", Province: "wrong"},
+			want:           oslo,
+			wantOK:         true,
+			wantConfidence: 1,
+		},
+		{
+			name:           "missing diacritic",
+			record:         LocationData{Latitude: "27.09", Longitude: "13.12", Geo: "27.09, 13.12", Name: "El Aaiun", Country: "Morocco"},
+			want:           elAaiun,
+			wantOK:         true,
+			wantConfidence: maxFuzzyConfidence, // same place and name, but not the same key
+		},
+		{
+			name:   "typo in geo",
+			record: LocationData{Latitude: "34.02", Longitude: "6.50", Geo: "34.02, 6.50a", Name: "Rabat", Country: "Morocco"},
+			want:   rabat,
+			wantOK: true,
+		},
+		{
+			name:   "misspelt name without coordinates",
+			record: LocationData{Name: "Olso", Country: "Norway"},
+			want:   oslo,
+			wantOK: true,
+		},
+		{
+			name:   "same name beyond the suggestion radius",
+			record: LocationData{Latitude: "34.02", Longitude: "6.50", Geo: "34.02, 6.50", Name: "Oslo", Country: "Norway", Hemisphere: "NW"},
+			want:   oslo,
+			wantOK: true,
+		},
+		{
+			name:   "mistyped latitude far from the city",
+			record: LocationData{Latitude: "69.23", Longitude: "5.20", Geo: "69.23, 5.20", Name: "Bergen", Country: "Norway"},
+			want:   bergen,
+			wantOK: true,
+		},
+		{
+			name:   "nothing similar",
+			record: LocationData{Name: "Springfield", Country: "Atlantis"},
+			wantOK: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := SuggestReference(tt.record)
+			if ok != tt.wantOK {
+				t.Fatalf("SuggestReference() ok = %v, want %v (got %+v)", ok, tt.wantOK, got)
+			}
+			if !ok {
+				return
+			}
+			if got.Reference != tt.want {
+				t.Errorf("SuggestReference() = %s, want %s", got.Reference.Name, tt.want.Name)
+			}
+			if tt.wantConfidence != 0 && got.Confidence != tt.wantConfidence {
+				t.Errorf("SuggestReference() confidence = %v, want %v", got.Confidence, tt.wantConfidence)
+			}
+			if got.Confidence < minSuggestionConfidence || got.Confidence > 1 {
+				t.Errorf("SuggestReference() confidence %v out of range", got.Confidence)
+			}
+			if got.Confidence == 1 && GetUniqueKey(got.Reference) != GetUniqueKey(tt.record) {
+				t.Errorf("SuggestReference() confidence 1 without an exact key match")
+			}
+		})
+	}
+}
+
+func TestAttachSuggestions(t *testing.T) {
+	useReference(map[Key]LocationData{GetUniqueKey(oslo): oslo}, "")
+
+	results := AttachSuggestions([]LocationData{{Name: "Osloo", Country: "Norway"}, {Name: "Nowhere"}})
+	if len(results) != 2 {
+		t.Fatalf("AttachSuggestions() returned %d results, want 2", len(results))
+	}
+	if results[0].Suggestion == nil || results[0].Suggestion.Reference != oslo {
+		t.Errorf("AttachSuggestions()[0] = %+v, want suggestion %s", results[0].Suggestion, oslo.Name)
+	}
+	if results[1].Suggestion != nil {
+		t.Errorf("AttachSuggestions()[1] = %+v, want no suggestion", results[1].Suggestion)
+	}
+}
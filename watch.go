@@ -0,0 +1,275 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+)
+
+// WatchEvent outcome of validating one file in watch mode, one JSON line of the results log
+type WatchEvent struct {
+	Time              time.Time        `json:"time"`
+	File              string           `json:"file"`
+	Checksum          string           `json:"checksum"`
+	ReferenceSnapshot string           `json:"reference_snapshot"`
+	Valid             int              `json:"valid"`
+	Invalid           []RejectedRecord `json:"invalid,omitempty"`
+	Error             string           `json:"error,omitempty"`
+}
+
+// validatedFile what a file looked like when it was last validated
+type validatedFile struct {
+	stamp    fileStamp
+	checksum string
+	snapshot string
+}
+
+// DirWatcher validates the files of Dir once they stop changing. A file is
+// picked up when its size and mtime are the same on two consecutive polls,
+// and validated again only when its content or the reference data changed.
+type DirWatcher struct {
+	Dir     string
+	LogPath string
+
+	helpers   HelperUtils
+	checksum  func(string) (string, error)
+	pending   map[string]fileStamp
+	validated map[string]validatedFile
+}
+
+// NewDirWatcher watcher for dir appending to the results log at logPath.
+// Files already in the log are not validated again while they are unchanged.
+func NewDirWatcher(dir, logPath string, helpers HelperUtils) (*DirWatcher, error) {
+	w := &DirWatcher{
+		Dir:       dir,
+		LogPath:   logPath,
+		helpers:   helpers,
+		checksum:  fileChecksum,
+		pending:   make(map[string]fileStamp),
+		validated: make(map[string]validatedFile),
+	}
+
+	f, err := os.Open(logPath)
+	if errors.Is(err, os.ErrNotExist) {
+		return w, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		var event WatchEvent
+		if err := json.Unmarshal(scanner.Bytes(), &event); err != nil {
+			return nil, fmt.Errorf("%s: %w", logPath, err)
+		}
+		w.validated[event.File] = validatedFile{checksum: event.Checksum, snapshot: event.ReferenceSnapshot}
+	}
+	return w, scanner.Err()
+}
+
+// Poll scans Dir once, validates the files that are ready and appends their
+// outcome to the results log. A file that cannot be read is logged as
+// unprocessable, one removed while it is polled is skipped.
+func (w *DirWatcher) Poll() ([]WatchEvent, error) {
+	files, err := w.helpers.getAllFiles(w.Dir)
+	if err != nil {
+		return nil, err
+	}
+
+	ref := loadedReference()
+	snapshot := ref.snapshot
+
+	var events []WatchEvent
+	present := make(map[string]bool, len(files))
+	for _, file := range files {
+		present[file] = true
+
+		info, err := os.Stat(file)
+		if err != nil {
+			continue // removed since it was listed
+		}
+		stamp := fileStamp{modTime: info.ModTime(), size: info.Size()}
+
+		last, seen := w.validated[file]
+		if seen && last.stamp == stamp {
+			if last.snapshot == snapshot {
+				continue
+			}
+			// untouched since it was validated, only the reference data changed
+		} else if prev, ok := w.pending[file]; !ok || prev != stamp {
+			w.pending[file] = stamp // still being written, or first sighting
+			continue
+		}
+		delete(w.pending, file)
+
+		checksum, err := w.checksum(file)
+		if errors.Is(err, fs.ErrNotExist) {
+			continue // removed since it was stat'ed
+		}
+		if err != nil {
+			event := WatchEvent{Time: time.Now(), File: file, ReferenceSnapshot: snapshot, Error: err.Error()}
+			if err := w.appendLog(event); err != nil {
+				return events, err
+			}
+			w.validated[file] = validatedFile{stamp: stamp, snapshot: snapshot}
+			events = append(events, event)
+			continue
+		}
+		if seen && last.checksum == checksum && last.snapshot == snapshot {
+			last.stamp = stamp
+			w.validated[file] = last
+			continue
+		}
+
+		event := w.validate(ref, file, checksum)
+		if err := w.appendLog(event); err != nil {
+			return events, err
+		}
+		w.validated[file] = validatedFile{stamp: stamp, checksum: checksum, snapshot: event.ReferenceSnapshot}
+		events = append(events, event)
+	}
+
+	for file := range w.pending {
+		if !present[file] {
+			delete(w.pending, file)
+		}
+	}
+	return events, nil
+}
+
+func (w *DirWatcher) validate(ref *referenceData, file, checksum string) WatchEvent {
+	event := WatchEvent{Time: time.Now(), File: file, Checksum: checksum, ReferenceSnapshot: ref.snapshot}
+	result := ref.validateFile(file, w.helpers)
+	if result.Err != nil {
+		event.Error = result.Err.Error()
+		return event
+	}
+	invalid := result.Invalid()
+	event.Valid = len(result.Records) - len(invalid)
+	if len(invalid) > 0 {
+		event.Invalid = ref.rejectedRecords(invalid)
+	}
+	return event
+}
+
+func (w *DirWatcher) appendLog(event WatchEvent) error {
+	f, err := os.OpenFile(w.LogPath, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o644)
+	if err != nil {
+		return err
+	}
+	if err := json.NewEncoder(f).Encode(event); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// Run polls every interval until ctx is done
+func (w *DirWatcher) Run(ctx context.Context, interval time.Duration) error {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		events, err := w.Poll()
+		for _, event := range events {
+			if event.Error != "" {
+				fmt.Printf("Unprocessable file %s: %s\n", event.File, event.Error)
+				continue
+			}
+			fmt.Printf("Validated %s: %d valid, %d invalid\n", event.File, event.Valid, len(event.Invalid))
+		}
+		if err != nil {
+			return err
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// runWatch handles `watch [flags] [dir]`
+func runWatch(args []string) int {
+	fs := flag.NewFlagSet("watch", flag.ExitOnError)
+	applyNormalization := addNormalizationFlags(fs)
+	applyLogging := addLogFlags(fs)
+	reference := addReferenceFlags(fs)
+	interval := fs.Duration("interval", 2*time.Second, "how often to scan the directory")
+	logPath := fs.String("log", "watch-results.jsonl", "results log, one JSON line per validated file")
+	referencePoll := fs.Duration("reference-poll", 30*time.Second, "how often to check the reference files for changes, 0 disables polling")
+	metricsAddr := fs.String("metrics-addr", "", "serve Prometheus metrics on this address, e.g. :9090")
+	_ = fs.Parse(args)
+
+	if err := applyLogging(); err != nil {
+		slog.Error("parsing flags", "err", err)
+		return 2
+	}
+	if err := applyNormalization(); err != nil {
+		slog.Error("parsing flags", "err", err)
+		return 2
+	}
+	policy, err := reference.policy()
+	if err != nil {
+		slog.Error("parsing flags", "err", err)
+		return 2
+	}
+	if *interval <= 0 {
+		slog.Error("parsing flags", "err", "-interval must be positive")
+		return 2
+	}
+
+	dir := "tmp"
+	if fs.NArg() > 0 {
+		dir = fs.Arg(0)
+	}
+
+	reloader := NewReferenceReloader(policy, reference.sources()...)
+	if _, err := reloader.Reload(); err != nil {
+		slog.Error("loading authentic cities", "err", err)
+		return 1
+	}
+
+	watcher, err := NewDirWatcher(dir, *logPath, HelperUtils{readData, parseCityFile, GetUniqueKey, hardCheck, getAllFiles})
+	if err != nil {
+		slog.Error("reading results log", "log", *logPath, "err", err)
+		return 1
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	go reloader.Watch(ctx, *referencePoll)
+
+	if *metricsAddr != "" {
+		mux := http.NewServeMux()
+		mux.Handle("GET /metrics", metrics.registry)
+		server := &http.Server{Addr: *metricsAddr, Handler: mux, ReadHeaderTimeout: 10 * time.Second}
+		go func() {
+			if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+				slog.Error("serving metrics", "addr", *metricsAddr, "err", err)
+			}
+		}()
+		defer server.Close()
+	}
+
+	slog.Info("watching", "dir", dir, "interval", *interval, "log", *logPath)
+	if err := watcher.Run(ctx, *interval); err != nil {
+		slog.Error("watching files", "err", err)
+		return 1
+	}
+	return 0
+}
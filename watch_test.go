@@ -0,0 +1,138 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestDirWatcherPoll(t *testing.T) {
+	useReference(map[Key]LocationData{GetUniqueKey(oslo): oslo}, "")
+	dir := t.TempDir()
+	logPath := filepath.Join(t.TempDir(), "results.jsonl")
+	path := filepath.Join(dir, "city-1.json")
+	helpers := HelperUtils{readData, parseCityFile, GetUniqueKey, hardCheck, getAllFiles}
+
+	watcher, err := NewDirWatcher(dir, logPath, helpers)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	steps := []struct {
+		name       string
+		change     func()
+		wantEvents int
+	}{
+		{"empty directory", func() {}, 0},
+		{"new file is not stable yet", func() { writeReferenceFile(t, path, []LocationData{oslo}) }, 0},
+		{"stable file is validated", func() {}, 1},
+		{"unchanged file is skipped", func() {}, 0},
+		{"rewritten file waits again", func() { writeReferenceFile(t, path, []LocationData{oslo, rabat}) }, 0},
+		{"rewritten file is validated", func() {}, 1},
+		{"touched file", func() {
+			later := time.Now().Add(time.Hour)
+			if err := os.Chtimes(path, later, later); err != nil {
+				t.Fatal(err)
+			}
+		}, 0},
+		{"touched file with the same content is skipped", func() {}, 0},
+	}
+	for _, step := range steps {
+		step.change()
+		events, err := watcher.Poll()
+		if err != nil {
+			t.Fatalf("%s: Poll() error = %v", step.name, err)
+		}
+		if len(events) != step.wantEvents {
+			t.Fatalf("%s: Poll() returned %d events, want %d", step.name, len(events), step.wantEvents)
+		}
+	}
+
+	restarted, err := NewDirWatcher(dir, logPath, helpers)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 2; i++ {
+		if events, err := restarted.Poll(); err != nil || len(events) != 0 {
+			t.Fatalf("Poll() after restart = %v, %v, want no events", events, err)
+		}
+	}
+
+	data, err := os.ReadFile(logPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if lines := bytes.Count(data, []byte("\n")); lines != 2 {
+		t.Errorf("results log has %d lines, want 2", lines)
+	}
+}
+
+func TestDirWatcherEvent(t *testing.T) {
+	useReference(map[Key]LocationData{GetUniqueKey(oslo): oslo}, "")
+	dir := t.TempDir()
+	writeReferenceFile(t, filepath.Join(dir, "city-1.json"), []LocationData{oslo, rabat})
+	if err := os.WriteFile(filepath.Join(dir, "city-2.json"), []byte("[{"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	watcher, err := NewDirWatcher(dir, filepath.Join(t.TempDir(), "results.jsonl"), HelperUtils{readData, parseCityFile, GetUniqueKey, hardCheck, getAllFiles})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := watcher.Poll(); err != nil {
+		t.Fatal(err)
+	}
+	events, err := watcher.Poll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(events) != 2 {
+		t.Fatalf("Poll() returned %d events, want 2", len(events))
+	}
+
+	if events[0].Valid != 1 || len(events[0].Invalid) != 1 || events[0].Invalid[0].Index != 1 {
+		t.Errorf("event for city-1.json = %+v, want 1 valid and record 1 invalid", events[0])
+	}
+	if events[1].Error == "" {
+		t.Errorf("event for city-2.json = %+v, want a parse error", events[1])
+	}
+}
+
+func TestDirWatcherUnreadableFiles(t *testing.T) {
+	useReference(map[Key]LocationData{GetUniqueKey(oslo): oslo}, "")
+	dir := t.TempDir()
+	removed := filepath.Join(dir, "city-1.json")
+	writeReferenceFile(t, removed, []LocationData{oslo})
+	if err := os.Mkdir(filepath.Join(dir, "sub.json"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	watcher, err := NewDirWatcher(dir, filepath.Join(t.TempDir(), "results.jsonl"), HelperUtils{readData, parseCityFile, GetUniqueKey, hardCheck, getAllFiles})
+	if err != nil {
+		t.Fatal(err)
+	}
+	watcher.checksum = func(path string) (string, error) {
+		if path == removed {
+			if err := os.Remove(path); err != nil {
+				t.Fatal(err)
+			}
+		}
+		return fileChecksum(path)
+	}
+
+	if _, err := watcher.Poll(); err != nil {
+		t.Fatal(err)
+	}
+	events, err := watcher.Poll()
+	if err != nil {
+		t.Fatalf("Poll() error = %v, want the watcher to carry on", err)
+	}
+	if len(events) != 1 || events[0].File != filepath.Join(dir, "sub.json") || events[0].Error == "" {
+		t.Fatalf("Poll() = %+v, want sub.json unprocessable and nothing for the removed file", events)
+	}
+	if events, err := watcher.Poll(); err != nil || len(events) != 0 {
+		t.Errorf("Poll() again = %+v, %v, want sub.json reported only once", events, err)
+	}
+}